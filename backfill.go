@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	// backfillRetries is how many more rounds the notes that failed during a
+	// backfill get before it gives up on them. They are not left to the retry
+	// queue, which works with the normal lookback window and would drop them
+	// as too old.
+	backfillRetries    = 2
+	backfillRetryDelay = time.Minute
+
+	// backfillRunning keeps the service to one backfill at a time.
+	backfillRunning atomic.Bool
+
+	// serviceURL is where the backfill command finds the running service.
+	serviceURL = "http://localhost:8080"
+)
+
+// errNotesLocked is returned by lockNotes when another process holds the
+// notes lock.
+var errNotesLocked = errors.New("otro proceso de tareasgenerador está procesando notas")
+
+// BackfillRequest is the body of POST /backfill. Dirs defaults to every note
+// root.
+type BackfillRequest struct {
+	Since string   `json:"since"`
+	Dirs  []string `json:"dirs,omitempty"`
+}
+
+// parse validates the request and fills in the default directories.
+func (req *BackfillRequest) parse() (time.Time, error) {
+	since, err := time.Parse(DateFormat, req.Since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since debe tener el formato YYYY-MM-DD: %q", req.Since)
+	}
+	if len(req.Dirs) == 0 {
+		for _, root := range configSnapshot().Roots {
+			req.Dirs = append(req.Dirs, root.Path)
+		}
+	}
+	if len(req.Dirs) == 0 {
+		return time.Time{}, fmt.Errorf("no hay directorios de notas configurados")
+	}
+	return since, nil
+}
+
+// backfill processes, once, every note in dirs dated from since on, ignoring
+// the lookback window, on notePool. Notes that fail are tried again after
+// backfillRetryDelay; the error lists the ones that never succeeded.
+func backfill(ctx context.Context, since time.Time, dirs []string) error {
+	var mu sync.Mutex
+	var failed []string
+	process := func(path string) {
+		if err := processNote(ctx, path, since); err != nil {
+			log.Printf("Error procesando %s: %v", path, err)
+			mu.Lock()
+			failed = append(failed, path)
+			mu.Unlock()
+		}
+	}
+
+	for _, dir := range dirs {
+		log.Printf("Procesando notas desde %s en %s...", since.Format(DateFormat), dir)
+		scanDirectory(ctx, dir, process)
+	}
+
+	for round := 1; round <= backfillRetries && len(failed) > 0; round++ {
+		log.Printf("Reintentando %d notas en %v (%d/%d)", len(failed), backfillRetryDelay, round, backfillRetries)
+		time.Sleep(backfillRetryDelay)
+		retry := failed
+		failed = nil
+		var wg sync.WaitGroup
+		for _, path := range retry {
+			wg.Add(1)
+			notePool.Go(func() {
+				defer wg.Done()
+				process(path)
+			})
+		}
+		wg.Wait()
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d notas no se pudieron procesar, vuelve a ejecutar el backfill más tarde: %s",
+			len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// runBackfillCommand implements "backfill -since YYYY-MM-DD [directorio...]".
+// When the service is running it holds the notes lock, so the backfill is
+// handed to it and runs there while it keeps serving; otherwise it runs
+// here.
+func runBackfillCommand(args []string, dbPath string) error {
+	fset := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	sinceFlag := fset.String("since", "", "procesar las notas desde esta fecha (YYYY-MM-DD)")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if *sinceFlag == "" {
+		return fmt.Errorf("uso: tareasgenerador backfill -since YYYY-MM-DD [directorio...]")
+	}
+	req := BackfillRequest{Since: *sinceFlag}
+	for _, dir := range fset.Args() {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		req.Dirs = append(req.Dirs, abs)
+	}
+	since, err := req.parse()
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockNotes(dbPath)
+	if errors.Is(err, errNotesLocked) {
+		return requestBackfill(req)
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return backfill(context.Background(), since, req.Dirs)
+}
+
+// requestBackfill asks the running service to run req.
+func requestBackfill(req BackfillRequest) error {
+	body, _ := json.Marshal(req)
+	resp, err := http.Post(serviceURL+"/backfill", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("el servicio tiene las notas bloqueadas pero no responde en %s: %w", serviceURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("el servicio rechazó el backfill: %s", strings.TrimSpace(string(msg)))
+	}
+	fmt.Printf("El servicio está en marcha y ejecuta el backfill desde %s; el progreso queda en su registro.\n", req.Since)
+	return nil
+}
+
+// backfillHandler starts a backfill in the service, which keeps serving while
+// it runs. Its progress and the notes that fail are logged.
+func backfillHandler(w http.ResponseWriter, r *http.Request) {
+	var req BackfillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	since, err := req.parse()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !backfillRunning.CompareAndSwap(false, true) {
+		http.Error(w, "ya hay un backfill en curso", http.StatusConflict)
+		return
+	}
+
+	go func() {
+		defer backfillRunning.Store(false)
+		if err := backfill(context.Background(), since, req.Dirs); err != nil {
+			log.Printf("Backfill desde %s: %v", req.Since, err)
+			return
+		}
+		log.Printf("Backfill desde %s terminado.", req.Since)
+	}()
+	writeJSON(w, http.StatusAccepted, req)
+}
+
+// lockNotes takes the lock that keeps a single process extracting notes into
+// the database at dbPath: the service while it runs, or a backfill command
+// when it does not. The returned function releases it.
+func lockNotes(dbPath string) (func(), error) {
+	path := dbPath + ".lock"
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo %s: %w", path, err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w con %s", errNotesLocked, dbPath)
+		}
+		return nil, fmt.Errorf("error bloqueando %s: %w", path, err)
+	}
+	return func() { f.Close() }, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testDBPath returns a database path for the notes lock of a test.
+func testDBPath(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "tasks.db")
+}
+
+func TestBackfillProcessesOldNotes(t *testing.T) {
+	setupTestDB(t)
+	restoreConfig(t)
+	dir := t.TempDir()
+	cfg := builtinConfig
+	cfg.Extractor = "rules"
+	cfg.DateSources = []string{dateFromFilename, dateFromFrontmatter}
+	cfg.Roots = []RootConfig{{Path: dir}}
+	applyConfig(cfg)
+
+	os.MkdirAll(filepath.Join(dir, "Redes"), 0755)
+	old := filepath.Join(dir, "Redes", "2026-03-02 Clase.md")
+	older := filepath.Join(dir, "Redes", "2026-02-20 Clase.md")
+	front := filepath.Join(dir, "Redes", "Laboratorio.md")
+	os.WriteFile(old, []byte("- [ ] Configurar VLAN 10\n"), 0644)
+	os.WriteFile(older, []byte("- [ ] Instalar Packet Tracer\n"), 0644)
+	os.WriteFile(front, []byte("---\ndate: 2026-03-05\n---\n- [ ] Entregar práctica\n"), 0644)
+
+	// Fuera de la ventana de días no se procesan.
+	scanAndProcessDirectory(context.Background(), dir)
+	if tasks, _ := getTasksFromDB(); len(tasks) != 0 {
+		t.Fatalf("Expected old notes to be skipped, got %+v", tasks)
+	}
+
+	if err := runBackfillCommand([]string{"-since", "2026-03-01"}, testDBPath(t)); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := getTasksFromDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates := map[string]string{}
+	for _, task := range tasks {
+		dates[task.SourcePath] = task.NoteDate
+	}
+	if len(tasks) != 2 || dates[old] != "2026-03-02" || dates[front] != "2026-03-05" {
+		t.Errorf("Unexpected backfilled tasks: %+v", tasks)
+	}
+
+	if err := runBackfillCommand(nil, testDBPath(t)); err == nil {
+		t.Error("Expected an error without -since")
+	}
+}
+
+func TestBackfillRetriesFailedNotes(t *testing.T) {
+	setupTestDB(t)
+	restoreConfig(t)
+	fake := &fakeExtractor{
+		errs:   []error{errors.New("backend caído")},
+		answer: "- [ ] @{2026-03-09} / Redes / Configurar VLAN 10",
+	}
+	useFakeExtractor(t, fake)
+	original := backfillRetryDelay
+	backfillRetryDelay = 0
+	defer func() { backfillRetryDelay = original }()
+
+	dir := t.TempDir()
+	note := filepath.Join(dir, "Redes", "2026-03-02 Clase.md")
+	os.MkdirAll(filepath.Dir(note), 0755)
+	os.WriteFile(note, []byte("Configurar VLAN 10"), 0644)
+
+	if err := runBackfillCommand([]string{"-since", "2026-03-01", dir}, testDBPath(t)); err != nil {
+		t.Fatal(err)
+	}
+	if tasks, _ := tasksFromSource(note); len(tasks) != 1 {
+		t.Errorf("Failed note was not retried: %+v", tasks)
+	}
+	// La cola del servicio usa la ventana normal y descartaría la nota.
+	if due, _ := dueRetries(time.Now().Add(retryQueueMaxDelay)); len(due) != 0 {
+		t.Errorf("Backfill left notes in the retry queue: %+v", due)
+	}
+
+	fake.err = errors.New("backend caído")
+	os.WriteFile(note, []byte("Configurar VLAN 20"), 0644)
+	if err := runBackfillCommand([]string{"-since", "2026-03-01", dir}, testDBPath(t)); err == nil {
+		t.Error("Expected an error for notes that keep failing")
+	}
+}
+
+func TestLockNotes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tasks.db")
+	unlock, err := lockNotes(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockNotes(dbPath); !errors.Is(err, errNotesLocked) {
+		t.Errorf("Expected a second lock to fail with errNotesLocked, got %v", err)
+	}
+	unlock()
+	unlock, err = lockNotes(dbPath)
+	if err != nil {
+		t.Fatalf("Lock not released: %v", err)
+	}
+	unlock()
+}
+
+func TestBackfillRunsInTheService(t *testing.T) {
+	setupTestDB(t)
+	restoreConfig(t)
+	dir := t.TempDir()
+	cfg := builtinConfig
+	cfg.Extractor = "rules"
+	cfg.Roots = []RootConfig{{Path: dir}}
+	applyConfig(cfg)
+
+	note := filepath.Join(dir, "Redes", "2026-03-02 Clase.md")
+	os.MkdirAll(filepath.Dir(note), 0755)
+	os.WriteFile(note, []byte("- [ ] Configurar VLAN 10\n"), 0644)
+
+	ts := httptest.NewServer(newRouter())
+	defer ts.Close()
+	original := serviceURL
+	serviceURL = ts.URL
+	defer func() { serviceURL = original }()
+
+	// Con el servicio en marcha, el comando le delega el backfill.
+	dbPath := testDBPath(t)
+	unlock, err := lockNotes(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if err := runBackfillCommand([]string{"-since", "2026-03-01"}, dbPath); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for backfillRunning.Load() || len(mustTasksFromSource(t, note)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the backfill in the service")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if tasks := mustTasksFromSource(t, note); len(tasks) != 1 || tasks[0].NoteDate != "2026-03-02" {
+		t.Errorf("Unexpected backfilled tasks: %+v", tasks)
+	}
+}
+
+func TestBackfillHandlerErrors(t *testing.T) {
+	setupTestDB(t)
+	for _, body := range []string{"{", `{"since":"ayer","dirs":["/tmp"]}`} {
+		if rec := doRequest(t, newRouter(), "POST", "/backfill", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+
+	backfillRunning.Store(true)
+	defer backfillRunning.Store(false)
+	body, _ := json.Marshal(BackfillRequest{Since: "2026-03-01", Dirs: []string{t.TempDir()}})
+	if rec := doRequest(t, newRouter(), "POST", "/backfill", string(body)); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while a backfill runs, got %d", rec.Code)
+	}
+}
+
+func mustTasksFromSource(t *testing.T, path string) []Pendiente {
+	t.Helper()
+	mutex.RLock()
+	defer mutex.RUnlock()
+	tasks, err := tasksFromSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tasks
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// promptlessExtractor is implemented by backends that do not use the prompt
+// templates, such as the rules extractor. Their results are neither cached
+// nor tagged with a prompt version.
+type promptlessExtractor interface {
+	promptless()
+}
+
+// extractionCacheKey identifies an extraction: the hash covers the user
+// prompt, that is the section content along with subject, filename and
+// current date, since the model resolves relative dates against it. The
+// prompt version covers the system prompt and the examples.
+type extractionCacheKey struct {
+	Hash          string
+	Backend       string
+	Model         string
+	PromptVersion string
+}
+
+func cacheKeyFor(ext TaskExtractor, req ExtractionRequest) (extractionCacheKey, error) {
+	ps, err := req.prompts()
+	if err != nil {
+		return extractionCacheKey{}, err
+	}
+	user, err := ps.render("user", newPromptData(req))
+	if err != nil {
+		return extractionCacheKey{}, err
+	}
+	return extractionCacheKey{
+		Hash:          contentHash([]byte(user)),
+		Backend:       ext.Name(),
+		Model:         ext.Model(),
+		PromptVersion: ps.Version,
+	}, nil
+}
+
+func usesPrompt(ext TaskExtractor) bool {
+	_, promptless := ext.(promptlessExtractor)
+	return !promptless
+}
+
+// cachedExtraction returns the tasks stored for key. Callers must hold mutex
+// for reading.
+func cachedExtraction(key extractionCacheKey) ([]ExtractedTask, bool, error) {
+	var data string
+	err := db.QueryRow(`SELECT tasks FROM extraction_cache
+		WHERE content_hash = ? AND backend = ? AND model = ? AND prompt_version = ?`,
+		key.Hash, key.Backend, key.Model, key.PromptVersion).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error querying extraction cache: %w", err)
+	}
+	var tasks []ExtractedTask
+	if err := json.Unmarshal([]byte(data), &tasks); err != nil {
+		return nil, false, fmt.Errorf("error decoding cached extraction: %w", err)
+	}
+	return tasks, true, nil
+}
+
+// storeExtraction caches tasks under key. Callers must hold mutex.
+func storeExtraction(key extractionCacheKey, tasks []ExtractedTask) error {
+	if tasks == nil {
+		tasks = []ExtractedTask{}
+	}
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return fmt.Errorf("error encoding extraction: %w", err)
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO extraction_cache(content_hash, backend, model, prompt_version, tasks, created_at)
+		VALUES(?, ?, ?, ?, ?, ?)`,
+		key.Hash, key.Backend, key.Model, key.PromptVersion, string(data), time.Now().Format(TimeFormat))
+	if err != nil {
+		return fmt.Errorf("error storing extraction: %w", err)
+	}
+	return nil
+}
+
+// clearExtractionCache removes the cached extractions of backend, or all of
+// them when backend is empty, and returns how many were removed.
+func clearExtractionCache(backend string) (int64, error) {
+	query, args := "DELETE FROM extraction_cache", []any{}
+	if backend != "" {
+		query, args = query+" WHERE backend = ?", []any{backend}
+	}
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error clearing extraction cache: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// pruneExtractionCache removes extractions made with a prompt version that
+// is no longer in use for any subject.
+func pruneExtractionCache() (int64, error) {
+	versions, err := currentPromptVersions()
+	if err != nil {
+		return 0, err
+	}
+	query, args := "DELETE FROM extraction_cache WHERE prompt_version NOT IN (", []any{}
+	for _, v := range versions {
+		query += "?,"
+		args = append(args, v)
+	}
+	query = strings.TrimSuffix(query, ",") + ")"
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning extraction cache: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// runCacheCommand implements the "cache status", "cache clear [backend]" and
+// "cache prune" subcommands.
+func runCacheCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: tareasgenerador cache status|clear [backend]|prune")
+	}
+
+	switch args[0] {
+	case "status":
+		rows, err := db.Query(`SELECT backend, model, prompt_version, COUNT(*) FROM extraction_cache
+			GROUP BY backend, model, prompt_version ORDER BY backend, model, prompt_version`)
+		if err != nil {
+			return fmt.Errorf("error querying extraction cache: %w", err)
+		}
+		defer rows.Close()
+		versions, err := currentPromptVersions()
+		if err != nil {
+			return err
+		}
+		for subject, v := range versions {
+			if subject == "" {
+				subject = "(por defecto)"
+			}
+			fmt.Printf("Prompt actual de %s: %s\n", subject, v)
+		}
+		for rows.Next() {
+			var backend, model, version string
+			var count int
+			if err := rows.Scan(&backend, &model, &version, &count); err != nil {
+				return err
+			}
+			fmt.Printf("%-8s %-28s prompt %-12s %d entradas\n", backend, model, version, count)
+		}
+		return rows.Err()
+	case "clear":
+		if len(args) > 2 {
+			return fmt.Errorf("uso: tareasgenerador cache clear [backend]")
+		}
+		backend := ""
+		if len(args) == 2 {
+			backend = args[1]
+		}
+		n, err := clearExtractionCache(backend)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d entradas eliminadas.\n", n)
+		return nil
+	case "prune":
+		n, err := pruneExtractionCache()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d entradas de versiones anteriores del prompt eliminadas.\n", n)
+		return nil
+	default:
+		return fmt.Errorf("subcomando de cache desconocido: %s", args[0])
+	}
+}
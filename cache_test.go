@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExtractionCache(t *testing.T) {
+	setupTestDB(t)
+	fake := &fakeExtractor{answer: "- [ ] @{2026-10-24} / Redes / Investigar OSPF"}
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local)
+	req := ExtractionRequest{Content: "Investigar OSPF", Filename: "a.md", Subject: "Redes", Now: now}
+
+	for i := 0; i < 2; i++ {
+		tasks, err := extractNoteTasks(context.Background(), configSnapshot(), fake, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 1 || tasks[0].Description != "Investigar OSPF" {
+			t.Fatalf("Unexpected tasks: %+v", tasks)
+		}
+	}
+	if len(fake.requests) != 1 {
+		t.Errorf("Identical request was sent %d times", len(fake.requests))
+	}
+
+	// Otro día las fechas relativas cambian, así que no se reutiliza.
+	req.Now = now.AddDate(0, 0, 1)
+	extractNoteTasks(context.Background(), configSnapshot(), fake, req)
+	if len(fake.requests) != 2 {
+		t.Errorf("Request for another day should not hit the cache")
+	}
+
+	if n, err := clearExtractionCache("otro"); err != nil || n != 0 {
+		t.Errorf("clear of another backend removed %d entries (err=%v)", n, err)
+	}
+	if n, err := clearExtractionCache(""); err != nil || n != 2 {
+		t.Errorf("Expected 2 entries cleared, got %d (err=%v)", n, err)
+	}
+	extractNoteTasks(context.Background(), configSnapshot(), fake, req)
+	if len(fake.requests) != 3 {
+		t.Errorf("Cleared cache was still used")
+	}
+}
+
+func TestExtractionCacheSkipsInvalidAnswers(t *testing.T) {
+	setupTestDB(t)
+	fake := &fakeExtractor{answer: "Claro, aquí tienes:"}
+	req := ExtractionRequest{Content: "nota", Now: time.Now()}
+
+	extractNoteTasks(context.Background(), configSnapshot(), fake, req)
+	extractNoteTasks(context.Background(), configSnapshot(), fake, req)
+	if len(fake.requests) != 2*(configSnapshot().RepairAttempts+1) {
+		t.Errorf("Invalid answers should not be cached, got %d requests", len(fake.requests))
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// estimateTokens approximates the number of tokens of text. Backends use
+// different tokenizers, none of them available offline, so this uses the
+// usual rule of thumb of four characters per token, which overestimates
+// Spanish prose a little and underestimates code a little.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// chunkSection splits a section that does not fit in maxTokens into chunks
+// that do. It cuts at blank lines outside code fences, so paragraphs and
+// code blocks stay whole where possible; a block that is too big on its own
+// is cut at line ends, and a single huge line at rune boundaries. Every
+// chunk after the first repeats the section's heading, so the model knows
+// what the text is about.
+func chunkSection(text string, maxTokens int) []string {
+	if maxTokens <= 0 || estimateTokens(text) <= maxTokens {
+		return []string{text}
+	}
+
+	heading := ""
+	if first, _, _ := strings.Cut(text, "\n"); strings.HasPrefix(first, "#") {
+		heading = first + "\n"
+	}
+	budget := maxTokens - estimateTokens(heading)
+	if budget < maxTokens/2 {
+		// Un encabezado enorme no se repite.
+		heading, budget = "", maxTokens
+	}
+
+	var pieces []string
+	for _, block := range markdownBlocks(text) {
+		if estimateTokens(block) <= budget {
+			pieces = append(pieces, block)
+			continue
+		}
+		for _, line := range strings.SplitAfter(block, "\n") {
+			pieces = append(pieces, splitRunes(line, budget*4)...)
+		}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if strings.TrimSpace(current.String()) != "" {
+			chunks = append(chunks, current.String())
+		}
+		current.Reset()
+	}
+	for _, piece := range pieces {
+		if current.Len() > 0 && estimateTokens(current.String()+piece) > budget {
+			flush()
+		}
+		if current.Len() == 0 && len(chunks) > 0 {
+			current.WriteString(heading)
+		}
+		current.WriteString(piece)
+	}
+	flush()
+	return chunks
+}
+
+// markdownBlocks splits text after each blank line that is not inside a code
+// fence. Concatenating the blocks gives back text.
+func markdownBlocks(text string) []string {
+	var blocks []string
+	var current strings.Builder
+	inFence := false
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		current.WriteString(line)
+		if !inFence && trimmed == "" {
+			blocks = append(blocks, current.String())
+			current.Reset()
+		}
+	}
+	if current.Len() > 0 {
+		blocks = append(blocks, current.String())
+	}
+	return blocks
+}
+
+// splitRunes cuts s into pieces of at most n runes.
+func splitRunes(s string, n int) []string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return []string{s}
+	}
+	var pieces []string
+	runes := []rune(s)
+	for len(runes) > n {
+		pieces = append(pieces, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return append(pieces, string(runes))
+}
+
+// mergeExtractedTasks drops tasks that more than one chunk of a section
+// reported, such as a task near a chunk boundary or read by both the rules
+// prepass and the LLM. Duplicates are recognised the way reconcileNoteTasks
+// does, by section and normalized description; the same text in two sections
+// is two tasks. The copy kept is the one with a due date, then the one with
+// the highest confidence, in the place of the first occurrence.
+func mergeExtractedTasks(tasks []Pendiente) []Pendiente {
+	var merged []Pendiente
+	type taskKey struct{ section, description string }
+	index := make(map[taskKey]int, len(tasks))
+	for _, p := range tasks {
+		key := taskKey{p.SourceSection, normalizeDescription(p.Description)}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, p)
+			continue
+		}
+		prev := merged[i]
+		if (prev.DueDate == "") != (p.DueDate == "") {
+			if prev.DueDate == "" {
+				merged[i] = p
+			}
+		} else if p.Confidence > prev.Confidence {
+			merged[i] = p
+		}
+	}
+	return merged
+}
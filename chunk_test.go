@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkSection(t *testing.T) {
+	if chunks := chunkSection("# Clase\nCorto\n", 100); len(chunks) != 1 {
+		t.Fatalf("Expected a short section to stay whole, got %q", chunks)
+	}
+
+	paragraph := strings.Repeat("palabra ", 20) + "\n\n" // 160 runas, 40 tokens
+	code := "```go\n" + strings.Repeat("x := 1\n\n", 5) + "```\n\n"
+	text := "# Clase\n" + paragraph + code + paragraph + paragraph
+	chunks := chunkSection(text, 50)
+
+	if len(chunks) < 3 {
+		t.Fatalf("Expected the section to be split, got %q", chunks)
+	}
+	var rebuilt strings.Builder
+	for i, chunk := range chunks {
+		if estimateTokens(chunk) > 50 {
+			t.Errorf("chunk %d has %d tokens: %q", i, estimateTokens(chunk), chunk)
+		}
+		if !strings.HasPrefix(chunk, "# Clase\n") {
+			t.Errorf("chunk %d lost the heading: %q", i, chunk)
+		}
+		if i > 0 {
+			chunk = strings.TrimPrefix(chunk, "# Clase\n")
+		}
+		if strings.Count(chunk, "```")%2 != 0 {
+			t.Errorf("chunk %d splits the code block: %q", i, chunk)
+		}
+		rebuilt.WriteString(chunk)
+	}
+	if rebuilt.String() != text {
+		t.Errorf("Chunks lost text:\n%q\nwant\n%q", rebuilt.String(), text)
+	}
+
+	long := strings.Repeat("a", 1000)
+	for _, chunk := range chunkSection(long, 50) {
+		if estimateTokens(chunk) > 50 {
+			t.Errorf("Long line not split: %d tokens", estimateTokens(chunk))
+		}
+	}
+}
+
+func TestMergeExtractedTasks(t *testing.T) {
+	tasks := []Pendiente{
+		{Description: "Investigar OSPF", Confidence: 0.5},
+		{Description: "Configurar VLAN", DueDate: "2026-10-20", Confidence: 0.9},
+		{Description: "investigar  ospf", DueDate: "2026-10-24", Confidence: 0.4},
+		{Description: "Configurar VLAN", DueDate: "2026-10-21", Confidence: 0.6},
+	}
+	merged := mergeExtractedTasks(tasks)
+	if len(merged) != 2 {
+		t.Fatalf("Expected 2 tasks, got %+v", merged)
+	}
+	if merged[0].DueDate != "2026-10-24" || merged[1].DueDate != "2026-10-20" {
+		t.Errorf("Unexpected merge: %+v", merged)
+	}
+}
+
+func TestProcessFileChunksLongSections(t *testing.T) {
+	setupTestDB(t)
+
+	var prompts []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Messages[len(req.Messages)-1].Content
+		prompts = append(prompts, prompt)
+		// Todos los fragmentos repiten la tarea del encabezado.
+		json.NewEncoder(w).Encode(ollamaAnswer(ExtractedTask{DueDate: "2026-10-24", Subject: "Redes", Description: "Entregar práctica OSPF"}))
+	}))
+	defer ts.Close()
+
+	useConfig(t, func(cfg *Config) {
+		cfg.Extractor = "ollama"
+		cfg.Ollama.URL = ts.URL
+		cfg.MaxChunkTokens = 60
+	})
+
+	note := filepath.Join(t.TempDir(), "Redes", time.Now().Format("2006-01-02")+" Redes.md")
+	os.MkdirAll(filepath.Dir(note), 0755)
+	content := "# Entregar práctica OSPF\n"
+	for _, topic := range []string{"áreas ", "LSA ", "costos ", "vecinos "} {
+		content += strings.Repeat(topic, 30) + "\n\n"
+	}
+	if err := os.WriteFile(note, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := processNote(context.Background(), note, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(prompts) < 2 {
+		t.Fatalf("Expected the section to be sent in several chunks, got %d", len(prompts))
+	}
+	for _, p := range prompts {
+		if !strings.Contains(p, "# Entregar práctica OSPF") {
+			t.Errorf("Chunk prompt lost the heading: %q", p)
+		}
+	}
+	tasks, err := tasksFromSource(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Errorf("Expected the chunks' tasks to be merged into 1, got %d", len(tasks))
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is everything that can be set in the configuration file. Keys left
+// out of the file keep their built-in default, and environment variables
+// override both, so a container can change a setting without a file.
+type Config struct {
+	// Extractor is the default fallback chain, e.g. "gemini,ollama,rules".
+	// Its backends are tried in order until one succeeds.
+	Extractor string `toml:"extractor" yaml:"extractor"`
+	// LookbackDays is how old a note can be, by its date, and still be
+	// processed.
+	LookbackDays int `toml:"lookback_days" yaml:"lookback_days"`
+	// DateSources are tried in order until one gives the note's date; a
+	// note without a date is not processed. DatePattern finds the date in a
+	// filename.
+	DateSources   []string      `toml:"date_sources" yaml:"date_sources"`
+	DatePattern   string        `toml:"date_pattern" yaml:"date_pattern"`
+	DateFormat    string        `toml:"date_format" yaml:"date_format"`
+	WatchDebounce time.Duration `toml:"watch_debounce" yaml:"watch_debounce"`
+	// ScanWorkers is how many notes are processed at the same time, between
+	// the scans, the watchers and the retry queue (see notePool).
+	ScanWorkers int `toml:"scan_workers" yaml:"scan_workers"`
+	// MaxChunkTokens is the largest piece of a note sent to the LLM in a
+	// single request, as estimated by estimateTokens.
+	MaxChunkTokens int `toml:"max_chunk_tokens" yaml:"max_chunk_tokens"`
+	// RepairAttempts is how many times an invalid answer is sent back to the
+	// backend with the validation errors before it is quarantined, and
+	// BackendRetries how many times a failed call is repeated before the
+	// note is left to the retry queue.
+	RepairAttempts int `toml:"repair_attempts" yaml:"repair_attempts"`
+	BackendRetries int `toml:"backend_retries" yaml:"backend_retries"`
+	// RulesPrepass makes the rule-based extractor read explicit tasks before
+	// the LLM, which then only sees the prose left over.
+	RulesPrepass    bool `toml:"rules_prepass" yaml:"rules_prepass"`
+	ExtractionCache bool `toml:"extraction_cache" yaml:"extraction_cache"`
+	// FrontmatterMarker keeps the old behaviour of writing
+	// procesado_por_ia: true into each note instead of tracking it in the DB.
+	FrontmatterMarker bool `toml:"frontmatter_marker" yaml:"frontmatter_marker"`
+	// PromptsDir holds prompt overrides: default.tmpl for every subject and
+	// <Materia>.tmpl for a single one. Empty means the embedded defaults
+	// only.
+	PromptsDir string `toml:"prompts_dir" yaml:"prompts_dir"`
+	// Ignore applies to every root, before the root's own patterns and its
+	// ignore files.
+	Ignore []string `toml:"ignore" yaml:"ignore"`
+
+	Ollama BackendConfig `toml:"ollama" yaml:"ollama"`
+	Gemini BackendConfig `toml:"gemini" yaml:"gemini"`
+	OpenAI BackendConfig `toml:"openai" yaml:"openai"`
+
+	Roots []RootConfig `toml:"roots" yaml:"roots"`
+
+	// roots are Roots with the global settings filled in, set by
+	// applyConfig.
+	roots  []RootConfig
+	dateRe *regexp.Regexp
+}
+
+// BackendConfig holds the settings of one extraction backend. URL is the
+// chat endpoint for Ollama and the base URL for OpenAI-compatible servers;
+// APIKey and StructuredOutput only apply to the latter.
+type BackendConfig struct {
+	URL              string        `toml:"url" yaml:"url"`
+	Model            string        `toml:"model" yaml:"model"`
+	APIKey           string        `toml:"api_key" yaml:"api_key"`
+	StructuredOutput bool          `toml:"structured_output" yaml:"structured_output"`
+	Timeout          time.Duration `toml:"timeout" yaml:"timeout"`
+	Concurrency      int           `toml:"concurrency" yaml:"concurrency"`
+	RPM              float64       `toml:"rpm" yaml:"rpm"`
+}
+
+// RootConfig is a notes directory. Extractor, LookbackDays and the date
+// settings default to the global ones. Subjects renames subject folders,
+// e.g. "Redes de Computadoras" to "Redes"; Ignore lists patterns of files and
+// folders that are neither scanned nor watched, on top of the global ones and
+// the root's .tareasignore files.
+type RootConfig struct {
+	Path         string            `toml:"path" yaml:"path"`
+	Extractor    string            `toml:"extractor" yaml:"extractor"`
+	Subjects     map[string]string `toml:"subjects" yaml:"subjects"`
+	LookbackDays int               `toml:"lookback_days" yaml:"lookback_days"`
+	DateSources  []string          `toml:"date_sources" yaml:"date_sources"`
+	DatePattern  string            `toml:"date_pattern" yaml:"date_pattern"`
+	DateFormat   string            `toml:"date_format" yaml:"date_format"`
+	Ignore       []string          `toml:"ignore" yaml:"ignore"`
+
+	matcher *ignoreMatcher
+	dateRe  *regexp.Regexp
+}
+
+var (
+	// builtinConfig holds the defaults, before the configuration file and
+	// the environment.
+	builtinConfig = Config{
+		Extractor:    "ollama",
+		LookbackDays: 7,
+		DateSources:  []string{dateFromFilename},
+		// The first group of the pattern, or the whole match if it has
+		// none, is parsed with DateFormat.
+		DatePattern:   `(\d{4}-\d{2}-\d{2})`,
+		DateFormat:    DateFormat,
+		WatchDebounce: 10 * time.Second,
+		ScanWorkers:   4,
+		// Leaves room for the system prompt and the few-shot examples in
+		// the 2048-token context many small local models run with.
+		MaxChunkTokens:  1500,
+		RepairAttempts:  2,
+		BackendRetries:  4,
+		ExtractionCache: true,
+		Ignore:          []string{".git/", ".obsidian/", ".trash/"},
+		// Ollama gets the longest timeout because the first request after
+		// a restart has to load the model, and answers one request at a
+		// time unless OLLAMA_NUM_PARALLEL is raised, so more concurrent
+		// calls would only queue there. Gemini's free tier answers 429
+		// above 15 requests per minute.
+		Ollama: BackendConfig{URL: "http://localhost:11434/api/chat", Timeout: 5 * time.Minute, Concurrency: 1},
+		Gemini: BackendConfig{Timeout: time.Minute, Concurrency: 4, RPM: 15},
+		OpenAI: BackendConfig{StructuredOutput: true, Timeout: 2 * time.Minute, Concurrency: 2},
+	}
+
+	// activeConfig is the configuration in use. applyConfig replaces it as a
+	// whole and a stored Config is never modified, so a note processed with
+	// the one it got when it started (see configSnapshot) never sees half
+	// the old settings and half the new ones, and a reload does not wait
+	// for the notes in flight.
+	activeConfig atomic.Pointer[Config]
+)
+
+// applyConfig makes cfg the configuration in use.
+func applyConfig(cfg Config) {
+	cfg.dateRe = compileDatePattern(cfg.DatePattern)
+	cfg.roots = nil
+	for _, root := range cfg.Roots {
+		root = cfg.withDefaults(root)
+		if root.DatePattern != cfg.DatePattern {
+			root.dateRe = compileDatePattern(root.DatePattern)
+		}
+		root.matcher = newIgnoreMatcher(root.Path, append(append([]string(nil), cfg.Ignore...), root.Ignore...))
+		cfg.roots = append(cfg.roots, root)
+	}
+	activeConfig.Store(&cfg)
+
+	notePool.resize(cfg.ScanWorkers)
+	resetPromptSets()
+	backendSlotsMu.Lock()
+	clear(backendSlots)
+	clear(backendLimits)
+	backendSlotsMu.Unlock()
+	unavailableMu.Lock()
+	clear(unavailableLogged)
+	unavailableMu.Unlock()
+}
+
+// withDefaults fills in the settings root leaves to the global ones.
+func (c *Config) withDefaults(root RootConfig) RootConfig {
+	if root.Extractor == "" {
+		root.Extractor = c.Extractor
+	}
+	if root.LookbackDays == 0 {
+		root.LookbackDays = c.LookbackDays
+	}
+	if len(root.DateSources) == 0 {
+		root.DateSources = c.DateSources
+	}
+	if root.DatePattern == "" {
+		root.DatePattern = c.DatePattern
+	}
+	if root.DatePattern == c.DatePattern {
+		root.dateRe = c.dateRe
+	}
+	if root.DateFormat == "" {
+		root.DateFormat = c.DateFormat
+	}
+	return root
+}
+
+func (c *Config) backends() map[string]*BackendConfig {
+	return map[string]*BackendConfig{"ollama": &c.Ollama, "gemini": &c.Gemini, "openai": &c.OpenAI}
+}
+
+// defaultConfigPath returns the configuration file in the user's config
+// directory ($XDG_CONFIG_HOME/tareasgenerador), or "" if there is none.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{"config.toml", "config.yaml", "config.yml"} {
+		path := filepath.Join(dir, "tareasgenerador", name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadConfig reads the configuration file at path, TOML or YAML by its
+// extension, over the built-in defaults and then applies the environment.
+// An empty path means defaultConfigPath, and no file at all means defaults
+// and environment only.
+func loadConfig(path string) (Config, error) {
+	cfg := builtinConfig
+
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("error leyendo la configuración: %w", err)
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".toml":
+			md, err := toml.Decode(string(data), &cfg)
+			if err != nil {
+				return Config{}, fmt.Errorf("error en %s: %w", path, err)
+			}
+			if undecoded := md.Undecoded(); len(undecoded) > 0 {
+				return Config{}, fmt.Errorf("error en %s: clave desconocida %s", path, undecoded[0])
+			}
+		case ".yaml", ".yml":
+			dec := yaml.NewDecoder(strings.NewReader(string(data)))
+			dec.KnownFields(true)
+			if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+				return Config{}, fmt.Errorf("error en %s: %w", path, err)
+			}
+		default:
+			return Config{}, fmt.Errorf("formato de configuración desconocido: %s (usa .toml o .yaml)", path)
+		}
+	}
+
+	applyEnv(&cfg)
+	return cfg, nil
+}
+
+// applyEnv overrides cfg with the environment variables that are set.
+// Invalid values are logged and ignored.
+func applyEnv(cfg *Config) {
+	envString := func(key string, dst *string) {
+		if v := os.Getenv(key); v != "" {
+			*dst = v
+		}
+	}
+	envList := func(key string, dst *[]string) {
+		if v := os.Getenv(key); v != "" {
+			*dst = nil
+			for _, item := range strings.Split(v, ",") {
+				*dst = append(*dst, strings.TrimSpace(item))
+			}
+		}
+	}
+	envBool := func(key string, dst *bool) {
+		if v := os.Getenv(key); v != "" {
+			if b, err := strconv.ParseBool(v); err == nil {
+				*dst = b
+			} else {
+				log.Printf("%s inválido (%q), usando %v", key, v, *dst)
+			}
+		}
+	}
+	envInt := func(key string, dst *int, min int) {
+		if v := os.Getenv(key); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= min {
+				*dst = n
+			} else {
+				log.Printf("%s inválido (%q), usando %d", key, v, *dst)
+			}
+		}
+	}
+	envDuration := func(key string, dst *time.Duration) {
+		if v := os.Getenv(key); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				*dst = d
+			} else {
+				log.Printf("%s inválido (%q), usando %v", key, v, *dst)
+			}
+		}
+	}
+
+	// DIRECTORIO_NOTAS reemplaza la ruta del primer directorio de notas.
+	if v := os.Getenv("DIRECTORIO_NOTAS"); v != "" {
+		if len(cfg.Roots) == 0 {
+			cfg.Roots = []RootConfig{{Path: v}}
+		} else {
+			cfg.Roots = append([]RootConfig(nil), cfg.Roots...)
+			cfg.Roots[0].Path = v
+		}
+	}
+	// EXTRACTOR elige el backend; USE_GEMINI="true" se mantiene por compatibilidad
+	if name := os.Getenv("EXTRACTOR"); name != "" {
+		cfg.Extractor = name
+	} else if os.Getenv("USE_GEMINI") == "true" {
+		cfg.Extractor = "gemini"
+	}
+	envString("OLLAMA_URL", &cfg.Ollama.URL)
+	envString("OLLAMA_MODEL", &cfg.Ollama.Model)
+	envString("GEMINI_MODEL", &cfg.Gemini.Model)
+	envString("OPENAI_BASE_URL", &cfg.OpenAI.URL)
+	envString("OPENAI_MODEL", &cfg.OpenAI.Model)
+	envString("OPENAI_API_KEY", &cfg.OpenAI.APIKey)
+	envBool("OPENAI_STRUCTURED_OUTPUT", &cfg.OpenAI.StructuredOutput)
+	envBool("FRONTMATTER_MARKER", &cfg.FrontmatterMarker)
+	envBool("RULES_PREPASS", &cfg.RulesPrepass)
+	envBool("EXTRACTION_CACHE", &cfg.ExtractionCache)
+	envString("PROMPTS_DIR", &cfg.PromptsDir)
+	envList("DATE_SOURCES", &cfg.DateSources)
+	envString("DATE_PATTERN", &cfg.DatePattern)
+	envString("DATE_FORMAT", &cfg.DateFormat)
+	envList("IGNORE_PATTERNS", &cfg.Ignore)
+	envInt("LOOKBACK_DAYS", &cfg.LookbackDays, 1)
+	envInt("REPAIR_ATTEMPTS", &cfg.RepairAttempts, 0)
+	envInt("BACKEND_RETRIES", &cfg.BackendRetries, 0)
+	envInt("MAX_CHUNK_TOKENS", &cfg.MaxChunkTokens, 0)
+	envInt("SCAN_WORKERS", &cfg.ScanWorkers, 1)
+	envDuration("WATCH_DEBOUNCE", &cfg.WatchDebounce)
+	for name, b := range cfg.backends() {
+		prefix := strings.ToUpper(name)
+		envDuration(prefix+"_TIMEOUT", &b.Timeout)
+		envInt(prefix+"_CONCURRENCY", &b.Concurrency, 1)
+		if v := os.Getenv(prefix + "_RPM"); v != "" {
+			if rpm, err := strconv.ParseFloat(v, 64); err == nil && rpm >= 0 {
+				b.RPM = rpm
+			} else {
+				log.Printf("%s_RPM inválido (%q), usando %v", prefix, v, b.RPM)
+			}
+		}
+	}
+}
+
+// loadAndApplyConfig loads and validates the configuration at path and
+// makes it the one in use.
+func loadAndApplyConfig(path string) error {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("configuración inválida:\n%w", err)
+	}
+	applyConfig(cfg)
+	return nil
+}
+
+// validate returns every problem found in cfg, joined, or nil.
+func (c *Config) validate() error {
+	var errs []error
+	checkChain := func(where, chain string) {
+		names := 0
+		for _, name := range strings.Split(chain, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			names++
+			if _, ok := extractorFactories[name]; !ok {
+				errs = append(errs, fmt.Errorf("%s: extractor desconocido %q (disponibles: %s)", where, name, strings.Join(extractorNames(), ", ")))
+			}
+		}
+		if names == 0 {
+			errs = append(errs, fmt.Errorf("%s: no hay ningún extractor", where))
+		}
+	}
+
+	checkDates := func(where string, sources []string, pattern string) {
+		for _, source := range sources {
+			if source != dateFromFilename && source != dateFromFrontmatter && source != dateFromMtime {
+				errs = append(errs, fmt.Errorf("%sdate_sources: fuente desconocida %q (disponibles: filename, frontmatter, mtime)", where, source))
+			}
+		}
+		if pattern != "" {
+			if _, err := regexp.Compile(pattern); err != nil {
+				errs = append(errs, fmt.Errorf("%sdate_pattern: %w", where, err))
+			}
+		}
+	}
+
+	checkChain("extractor", c.Extractor)
+	if c.LookbackDays < 1 {
+		errs = append(errs, fmt.Errorf("lookback_days debe ser al menos 1"))
+	}
+	if len(c.DateSources) == 0 {
+		errs = append(errs, fmt.Errorf("date_sources no puede estar vacío"))
+	}
+	checkDates("", c.DateSources, c.DatePattern)
+	if c.ScanWorkers < 1 {
+		errs = append(errs, fmt.Errorf("scan_workers debe ser al menos 1"))
+	}
+	if c.MaxChunkTokens < 0 || c.RepairAttempts < 0 || c.BackendRetries < 0 {
+		errs = append(errs, fmt.Errorf("max_chunk_tokens, repair_attempts y backend_retries no pueden ser negativos"))
+	}
+	if c.WatchDebounce < 0 {
+		errs = append(errs, fmt.Errorf("watch_debounce no puede ser negativo"))
+	}
+	for name, b := range c.backends() {
+		if b.Timeout < 0 || b.Concurrency < 0 || b.RPM < 0 {
+			errs = append(errs, fmt.Errorf("%s: timeout, concurrency y rpm no pueden ser negativos", name))
+		}
+	}
+	if c.PromptsDir != "" {
+		if info, err := os.Stat(c.PromptsDir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("prompts_dir: %s no es un directorio", c.PromptsDir))
+		}
+	}
+
+	checkIgnore := func(where string, patterns []string) {
+		for _, pattern := range patterns {
+			if _, _, err := parseIgnorePattern(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("%s: patrón inválido %q", where, pattern))
+			}
+		}
+	}
+	checkIgnore("ignore", c.Ignore)
+
+	seen := map[string]bool{}
+	for i, root := range c.Roots {
+		where := fmt.Sprintf("roots[%d]", i)
+		if root.Path == "" {
+			errs = append(errs, fmt.Errorf("%s: falta path", where))
+			continue
+		}
+		where = fmt.Sprintf("roots[%d] (%s)", i, root.Path)
+		if info, err := os.Stat(root.Path); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("%s: no es un directorio", where))
+		}
+		clean := filepath.Clean(root.Path)
+		if seen[clean] {
+			errs = append(errs, fmt.Errorf("%s: directorio repetido", where))
+		}
+		seen[clean] = true
+		if root.Extractor != "" {
+			checkChain(where+".extractor", root.Extractor)
+		}
+		if root.LookbackDays < 0 {
+			errs = append(errs, fmt.Errorf("%s: lookback_days no puede ser negativo", where))
+		}
+		checkDates(where+": ", root.DateSources, root.DatePattern)
+		checkIgnore(where, root.Ignore)
+	}
+	return errors.Join(errs...)
+}
+
+// configSnapshot returns the configuration in use. A note is processed with
+// the one it got when it starts. Callers must not modify it; to change a
+// setting, copy it and pass the copy to applyConfig.
+func configSnapshot() *Config {
+	return activeConfig.Load()
+}
+
+// backend returns the settings of the named backend; zero for unknown ones.
+func (c *Config) backend(name string) BackendConfig {
+	if b, ok := c.backends()[name]; ok {
+		return *b
+	}
+	return BackendConfig{}
+}
+
+// rootFor returns the root of c that contains path, the innermost one if
+// they are nested. Paths outside every root get the global settings.
+func (c *Config) rootFor(path string) RootConfig {
+	best := c.withDefaults(RootConfig{})
+	bestLen := -1
+	for _, root := range c.roots {
+		rel, err := filepath.Rel(root.Path, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if n := len(filepath.Clean(root.Path)); n > bestLen {
+			best, bestLen = root, n
+		}
+	}
+	return best
+}
+
+// subject returns the subject of a note in dir, the name of its folder or
+// the one it is mapped to.
+func (r RootConfig) subject(dir string) string {
+	folder := filepath.Base(dir)
+	if name, ok := r.Subjects[folder]; ok {
+		return name
+	}
+	return folder
+}
+
+// ignores reports whether path, a folder if isDir, is left alone by the scan
+// and the watcher, because of the global or root patterns or of a
+// .tareasignore file.
+func (r RootConfig) ignores(path string, isDir bool) bool {
+	return r.matcher != nil && r.matcher.ignores(path, isDir)
+}
+
+// runConfigCommand implements "config validate".
+func runConfigCommand(args []string, path string) error {
+	if len(args) == 0 || args[0] != "validate" || len(args) > 2 {
+		return fmt.Errorf("uso: tareasgenerador [-config archivo] config validate [archivo]")
+	}
+	if len(args) == 2 {
+		path = args[1]
+	}
+	if path == "" {
+		path = defaultConfigPath()
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		fmt.Println("Sin archivo de configuración, usando valores por defecto y variables de entorno.")
+	} else {
+		fmt.Printf("Archivo: %s\n", path)
+	}
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("configuración inválida:\n%w", err)
+	}
+
+	fmt.Printf("Extractor: %s\n", cfg.Extractor)
+	for _, root := range cfg.Roots {
+		root = cfg.withDefaults(root)
+		fmt.Printf("Notas: %s (extractor %s, %d días, fecha de %s", root.Path, root.Extractor, root.LookbackDays, strings.Join(root.DateSources, "/"))
+		if len(root.Subjects) > 0 {
+			fmt.Printf(", %d materias renombradas", len(root.Subjects))
+		}
+		if len(root.Ignore) > 0 {
+			fmt.Printf(", ignora %s", strings.Join(root.Ignore, " "))
+		}
+		fmt.Println(")")
+	}
+	if len(cfg.Roots) == 0 {
+		fmt.Println("ADVERTENCIA: no hay directorios de notas configurados")
+	}
+	fmt.Println("Configuración válida.")
+	return nil
+}
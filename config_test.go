@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// restoreConfig puts the configuration in use back after a test applies
+// another one.
+func restoreConfig(t *testing.T) {
+	saved := configSnapshot()
+	t.Cleanup(func() { applyConfig(*saved) })
+}
+
+// useConfig applies, for the rest of the test, a copy of the configuration
+// in use changed by edit.
+func useConfig(t *testing.T, edit func(cfg *Config)) {
+	t.Helper()
+	restoreConfig(t)
+	cfg := *configSnapshot()
+	edit(&cfg)
+	applyConfig(cfg)
+}
+
+func TestLoadConfigTOML(t *testing.T) {
+	notes := t.TempDir()
+	path := writeConfig(t, "config.toml", `
+extractor = "gemini,rules"
+watch_debounce = "3s"
+
+[ollama]
+model = "qwen2.5"
+timeout = "90s"
+concurrency = 2
+
+[gemini]
+rpm = 5
+
+[[roots]]
+path = "`+notes+`"
+extractor = "ollama"
+lookback_days = 30
+ignore = [".obsidian", "adjuntos/*"]
+
+[roots.subjects]
+"Redes de Computadoras" = "Redes"
+`)
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Extractor != "gemini,rules" || cfg.WatchDebounce != 3*time.Second {
+		t.Errorf("Unexpected globals: %+v", cfg)
+	}
+	if cfg.Ollama.Model != "qwen2.5" || cfg.Ollama.Timeout != 90*time.Second || cfg.Ollama.Concurrency != 2 {
+		t.Errorf("Unexpected ollama settings: %+v", cfg.Ollama)
+	}
+	// Lo que el archivo no menciona conserva el valor por defecto.
+	if cfg.Ollama.URL != builtinConfig.Ollama.URL || cfg.Gemini.Timeout != time.Minute || cfg.Gemini.RPM != 5 {
+		t.Errorf("Defaults lost: %+v %+v", cfg.Ollama, cfg.Gemini)
+	}
+	if len(cfg.Roots) != 1 || cfg.Roots[0].LookbackDays != 30 || cfg.Roots[0].Subjects["Redes de Computadoras"] != "Redes" {
+		t.Errorf("Unexpected roots: %+v", cfg.Roots)
+	}
+}
+
+func TestLoadConfigYAMLAndEnv(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	path := writeConfig(t, "config.yaml", `
+extractor: ollama
+scan_workers: 2
+openai:
+  url: http://localhost:1234/v1
+  structured_output: false
+roots:
+  - path: `+a+`
+  - path: `+b+`
+    lookback_days: 14
+`)
+	t.Setenv("SCAN_WORKERS", "8")
+	t.Setenv("OLLAMA_TIMEOUT", "10s")
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ScanWorkers != 8 || cfg.Ollama.Timeout != 10*time.Second {
+		t.Errorf("Environment did not override the file: %+v", cfg)
+	}
+	if cfg.OpenAI.URL != "http://localhost:1234/v1" || cfg.OpenAI.StructuredOutput {
+		t.Errorf("Unexpected openai settings: %+v", cfg.OpenAI)
+	}
+	if len(cfg.Roots) != 2 || cfg.Roots[1].LookbackDays != 14 {
+		t.Errorf("Unexpected roots: %+v", cfg.Roots)
+	}
+
+	// DIRECTORIO_NOTAS reemplaza solo el primer directorio.
+	c := t.TempDir()
+	t.Setenv("DIRECTORIO_NOTAS", c)
+	cfg, err = loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Roots[0].Path != c || cfg.Roots[1].Path != b {
+		t.Errorf("Unexpected roots with DIRECTORIO_NOTAS: %+v", cfg.Roots)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	if _, err := loadConfig(writeConfig(t, "config.toml", "extractr = \"ollama\"\n")); err == nil {
+		t.Error("Expected error for an unknown TOML key")
+	}
+	if _, err := loadConfig(writeConfig(t, "config.yaml", "roots:\n  - pth: /tmp\n")); err == nil {
+		t.Error("Expected error for an unknown YAML key")
+	}
+	if _, err := loadConfig(writeConfig(t, "config.json", "{}")); err == nil {
+		t.Error("Expected error for an unsupported format")
+	}
+
+	cfg, err := loadConfig(writeConfig(t, "config.toml", `
+extractor = "ollama,chatgpt"
+scan_workers = 0
+
+date_sources = ["filename", "ctime"]
+
+[[roots]]
+path = "/no/existe"
+ignore = ["[adjuntos"]
+date_pattern = "(\\d{4}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"chatgpt", "scan_workers", "/no/existe", "[adjuntos", "ctime", "date_pattern"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validation error does not mention %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestRootFor(t *testing.T) {
+	restoreConfig(t)
+	cfg := builtinConfig
+	cfg.Extractor, cfg.LookbackDays = "ollama", 7
+	cfg.Roots = []RootConfig{
+		{Path: "/notas", Subjects: map[string]string{"Redes de Computadoras": "Redes"}, Ignore: []string{".obsidian", "adjuntos/*"}},
+		{Path: "/notas/posgrado", Extractor: "gemini", LookbackDays: 30},
+	}
+	applyConfig(cfg)
+
+	root := configSnapshot().rootFor("/notas/Redes de Computadoras/2026-10-17 Clase.md")
+	if root.Path != "/notas" || root.Extractor != "ollama" || root.LookbackDays != 7 {
+		t.Errorf("Unexpected root: %+v", root)
+	}
+	if got := root.subject("/notas/Redes de Computadoras"); got != "Redes" {
+		t.Errorf("subject = %q, want Redes", got)
+	}
+	if got := root.subject("/notas/Algebra"); got != "Algebra" {
+		t.Errorf("subject = %q, want Algebra", got)
+	}
+
+	if root := configSnapshot().rootFor("/notas/posgrado/Tesis/a.md"); root.Extractor != "gemini" || root.LookbackDays != 30 {
+		t.Errorf("Expected the nested root, got %+v", root)
+	}
+	if root := configSnapshot().rootFor("/otras/a.md"); root.Path != "" || root.Extractor != "ollama" {
+		t.Errorf("Expected the global settings outside every root, got %+v", root)
+	}
+
+	for path, want := range map[string]bool{
+		"/notas/.obsidian":                 true,
+		"/notas/Redes/.obsidian/app.json":  true,
+		"/notas/adjuntos/diagrama.md":      true,
+		"/notas/Redes/adjuntos/a.md":       false,
+		"/notas/Redes/2026-10-17 Clase.md": false,
+	} {
+		if got := root.ignores(path, filepath.Ext(path) == ""); got != want {
+			t.Errorf("ignores(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestScanSkipsIgnoredDirectories(t *testing.T) {
+	setupTestDB(t)
+	restoreConfig(t)
+	dir := t.TempDir()
+	cfg := builtinConfig
+	cfg.Extractor = "rules"
+	cfg.Roots = []RootConfig{{Path: dir, Ignore: []string{"plantillas"}, Subjects: map[string]string{"Redes de Computadoras": "Redes"}}}
+	applyConfig(cfg)
+
+	date := time.Now().Format("2006-01-02")
+	for _, folder := range []string{"Redes de Computadoras", "plantillas"} {
+		os.MkdirAll(filepath.Join(dir, folder), 0755)
+		os.WriteFile(filepath.Join(dir, folder, date+" Nota.md"), []byte("- [ ] Tarea en "+folder+"\n"), 0644)
+	}
+
+	scanAndProcessDirectory(context.Background(), dir)
+
+	tasks, err := getTasksFromDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Subject != "Redes" || tasks[0].SourceSubject != "Redes" {
+		t.Errorf("Expected only the mapped subject's task, got %+v", tasks)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Date sources a root can take its notes' dates from.
+const (
+	dateFromFilename    = "filename"
+	dateFromFrontmatter = "frontmatter"
+	dateFromMtime       = "mtime"
+)
+
+// frontmatterDateLayouts are the formats accepted in a "date:" field.
+var frontmatterDateLayouts = []string{DateFormat, "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339}
+
+// noteDate returns the date of the note at path from the first of the root's
+// date sources that has one.
+func (r RootConfig) noteDate(path string, info fs.FileInfo) (time.Time, bool, error) {
+	for _, source := range r.DateSources {
+		switch source {
+		case dateFromFilename:
+			if d, ok := filenameDate(filepath.Base(path), r.dateRe, r.DateFormat); ok {
+				return d, true, nil
+			}
+		case dateFromFrontmatter:
+			d, ok, err := frontmatterDate(path)
+			if err != nil {
+				return time.Time{}, false, err
+			}
+			if ok {
+				return d, true, nil
+			}
+		case dateFromMtime:
+			return info.ModTime(), true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// inWindow reports whether a note dated date is recent enough to process.
+// A non-zero since replaces the lookback window, as in a backfill: notes
+// dated from that day on are processed however old they are.
+func (r RootConfig) inWindow(date, since time.Time) bool {
+	if !since.IsZero() {
+		return !date.Before(since)
+	}
+	return time.Since(date).Hours() <= float64(24*r.LookbackDays)
+}
+
+// compileDatePattern compiles a date_pattern. validate reports invalid
+// patterns when the configuration is loaded; one that gets here anyway is
+// logged and gives no dates.
+func compileDatePattern(pattern string) *regexp.Regexp {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Printf("date_pattern inválido %q: %v", pattern, err)
+		return nil
+	}
+	return re
+}
+
+func filenameDate(filename string, re *regexp.Regexp, layout string) (time.Time, bool) {
+	if re == nil {
+		return time.Time{}, false
+	}
+	m := re.FindStringSubmatch(filename)
+	if m == nil {
+		return time.Time{}, false
+	}
+	value := m[0]
+	if len(m) > 1 {
+		value = m[1]
+	}
+	d, err := time.Parse(layout, value)
+	return d, err == nil
+}
+
+// frontmatterDate reads the "date:" field of the note's YAML frontmatter,
+// without reading the rest of the note.
+func frontmatterDate(path string) (time.Time, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("error leyendo archivo: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return time.Time{}, false, nil
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "---" {
+			break
+		}
+		value, ok := strings.CutPrefix(line, "date:")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		for _, layout := range frontmatterDateLayouts {
+			if d, err := time.Parse(layout, value); err == nil {
+				return d, true, nil
+			}
+		}
+		return time.Time{}, false, nil
+	}
+	return time.Time{}, false, scanner.Err()
+}
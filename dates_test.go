@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFilenameDate(t *testing.T) {
+	tests := []struct {
+		filename, pattern, layout string
+		want                      string
+		ok                        bool
+	}{
+		{"2026-10-17 Clase.md", `(\d{4}-\d{2}-\d{2})`, DateFormat, "2026-10-17", true},
+		{"Seminario 17.10.2026.md", `(\d{2}\.\d{2}\.\d{4})`, "02.01.2006", "2026-10-17", true},
+		{"20261017.md", `\d{8}`, "20060102", "2026-10-17", true},
+		{"Clase.md", `(\d{4}-\d{2}-\d{2})`, DateFormat, "", false},
+		{"2026-13-45 Clase.md", `(\d{4}-\d{2}-\d{2})`, DateFormat, "", false},
+	}
+	for _, tt := range tests {
+		d, ok := filenameDate(tt.filename, regexp.MustCompile(tt.pattern), tt.layout)
+		if ok != tt.ok || (ok && d.Format(DateFormat) != tt.want) {
+			t.Errorf("filenameDate(%q) = %v, %v; want %s, %v", tt.filename, d, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestApplyConfigCompilesDatePatterns(t *testing.T) {
+	restoreConfig(t)
+	dir := t.TempDir()
+	cfg := builtinConfig
+	cfg.Roots = []RootConfig{
+		{Path: filepath.Join(dir, "a")},
+		{Path: filepath.Join(dir, "b"), DatePattern: `(\d{8})`, DateFormat: "20060102"},
+	}
+	applyConfig(cfg)
+
+	snapshot := configSnapshot()
+	for _, tt := range []struct{ path, want string }{
+		{filepath.Join(dir, "a", "2026-10-17 Clase.md"), "2026-10-17"},
+		{filepath.Join(dir, "b", "20261017 Clase.md"), "2026-10-17"},
+		{filepath.Join(dir, "fuera", "2026-10-17 Clase.md"), "2026-10-17"},
+	} {
+		root := snapshot.rootFor(tt.path)
+		if root.dateRe == nil {
+			t.Fatalf("date_pattern of %s was not compiled", tt.path)
+		}
+		d, ok := filenameDate(filepath.Base(tt.path), root.dateRe, root.DateFormat)
+		if !ok || d.Format(DateFormat) != tt.want {
+			t.Errorf("filenameDate(%s) = %v, %v; want %s", tt.path, d, ok, tt.want)
+		}
+	}
+}
+
+func TestNoteDateSources(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) (string, os.FileInfo) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Date(2026, 9, 1, 12, 0, 0, 0, time.Local)
+		os.Chtimes(path, mtime, mtime)
+		info, _ := os.Stat(path)
+		return path, info
+	}
+	root := RootConfig{
+		DateSources: []string{dateFromFilename, dateFromFrontmatter, dateFromMtime},
+		DateFormat:  DateFormat,
+		dateRe:      regexp.MustCompile(builtinConfig.DatePattern),
+	}
+
+	for _, tt := range []struct{ name, content, want string }{
+		{"2026-10-17 Clase.md", "---\ndate: 2026-10-01\n---\n", "2026-10-17"},
+		{"Clase.md", "---\ntitle: Clase\ndate: \"2026-10-01T09:30\"\n---\n- [ ] Tarea\n", "2026-10-01"},
+		{"Repaso.md", "- [ ] Tarea\ndate: 2026-10-01\n", "2026-09-01"},
+	} {
+		path, info := write(tt.name, tt.content)
+		d, ok, err := root.noteDate(path, info)
+		if err != nil || !ok || d.Format(DateFormat) != tt.want {
+			t.Errorf("noteDate(%s) = %v, %v, %v; want %s", tt.name, d, ok, err, tt.want)
+		}
+	}
+
+	// Sin mtime, una nota sin fecha no se procesa.
+	root.DateSources = []string{dateFromFrontmatter}
+	path, info := write("Sin fecha.md", "- [ ] Tarea\n")
+	if _, ok, err := root.noteDate(path, info); ok || err != nil {
+		t.Errorf("Expected no date, got %v, %v", ok, err)
+	}
+}
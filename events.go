@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Tipos de evento emitidos cuando cambia una tarea.
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// TaskEvent is the change notification pushed to live clients. For deleted
+// events Task holds the task as it was before deletion. Seq is the position
+// of the event in the task_events table and increases monotonically.
+type TaskEvent struct {
+	Seq  int64     `json:"seq"`
+	Type string    `json:"type"`
+	Task Pendiente `json:"task"`
+}
+
+// eventRetention is how many events are kept in task_events for clients that
+// reconnect with Last-Event-ID.
+const eventRetention = 1000
+
+// eventBufferSize is how many events a subscriber may fall behind before it
+// is dropped.
+const eventBufferSize = 64
+
+// eventHub fans task events out to every subscriber. Publishing never blocks:
+// a subscriber whose buffer is full is disconnected so one stalled client
+// cannot hold up the scanner or the API handlers.
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan TaskEvent]struct{}
+}
+
+var events = newEventHub()
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan TaskEvent]struct{})}
+}
+
+// subscribe registers a new listener. The returned channel is closed when the
+// listener is dropped or unsubscribe is called.
+func (h *eventHub) subscribe() (<-chan TaskEvent, func()) {
+	ch := make(chan TaskEvent, eventBufferSize)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() { h.remove(ch) }
+}
+
+func (h *eventHub) remove(ch chan TaskEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (h *eventHub) publish(ev TaskEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			log.Println("Cliente de eventos demasiado lento, desconectando.")
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// emitTaskEvent persists ev in task_events, so it can be replayed later, and
+// then notifies live subscribers. An event that could not be stored is not
+// sent: without a sequence number it would reset the position clients resume
+// from. Callers must hold mutex.
+func emitTaskEvent(ev TaskEvent) {
+	seq, err := insertTaskEvent(ev)
+	if err != nil {
+		log.Printf("Error guardando evento %s de la tarea %d: %v", ev.Type, ev.Task.ID, err)
+		return
+	}
+	ev.Seq = seq
+	events.publish(ev)
+}
+
+// publishTaskEvent loads the task with the given ID and emits an event for it.
+// Callers must hold mutex.
+func publishTaskEvent(eventType string, id int) {
+	p, err := getTaskFromDB(id)
+	if err != nil {
+		log.Printf("Error cargando tarea %d para notificar: %v", id, err)
+		return
+	}
+	emitTaskEvent(TaskEvent{Type: eventType, Task: p})
+}
+
+func insertTaskEvent(ev TaskEvent) (int64, error) {
+	payload, err := json.Marshal(ev.Task)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding event payload: %w", err)
+	}
+
+	res, err := db.Exec("INSERT INTO task_events(type, task_id, payload, created_at) VALUES(?, ?, ?, ?)",
+		ev.Type, ev.Task.ID, string(payload), time.Now().Format(TimeFormat))
+	if err != nil {
+		return 0, fmt.Errorf("error inserting event: %w", err)
+	}
+	seq, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error reading event sequence: %w", err)
+	}
+
+	if _, err := db.Exec("DELETE FROM task_events WHERE seq <= ?", seq-eventRetention); err != nil {
+		log.Printf("Error recortando task_events: %v", err)
+	}
+	return seq, nil
+}
+
+// taskEventsSince returns the stored events with a sequence greater than
+// after, oldest first. gap reports whether events after `after` have already
+// been pruned, in which case the caller cannot fully catch up.
+func taskEventsSince(after int64) (evs []TaskEvent, gap bool, err error) {
+	var oldest *int64
+	if err := db.QueryRow("SELECT MIN(seq) FROM task_events").Scan(&oldest); err != nil {
+		return nil, false, fmt.Errorf("error querying oldest event: %w", err)
+	}
+	gap = oldest != nil && *oldest > after+1
+
+	rows, err := db.Query("SELECT seq, type, payload FROM task_events WHERE seq > ? ORDER BY seq", after)
+	if err != nil {
+		return nil, false, fmt.Errorf("error querying events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ev TaskEvent
+		var payload string
+		if err := rows.Scan(&ev.Seq, &ev.Type, &payload); err != nil {
+			return nil, false, fmt.Errorf("error scanning event: %w", err)
+		}
+		if err := json.Unmarshal([]byte(payload), &ev.Task); err != nil {
+			return nil, false, fmt.Errorf("error decoding event %d: %w", ev.Seq, err)
+		}
+		evs = append(evs, ev)
+	}
+	return evs, gap, rows.Err()
+}
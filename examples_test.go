@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"testing"
@@ -16,6 +17,12 @@ type ExampleScenario struct {
 }
 
 func TestLLMExamplesReal(t *testing.T) {
+	cfg := configSnapshot()
+	if cfg.Extractor == "ollama" && cfg.Ollama.Model == "" {
+		t.Skip("OLLAMA_MODEL no definido, se omite la prueba contra el LLM real")
+	}
+	setupTestDB(t)
+
 	today := time.Now()
 	nextFriday := today.AddDate(0, 0, (12-int(today.Weekday()))%7)
 	if nextFriday.Before(today) {
@@ -86,28 +93,41 @@ Pagar el recibo de luz antes del 20.
 		},
 	}
 
-	backendName := "Ollama"
-	targetModel := ollamaModel
-	if useGemini {
-		backendName = "Gemini"
-		targetModel = geminiModel
+	chain, err := extractorsFor(cfg, cfg.Extractor)
+	if err != nil {
+		t.Fatal(err)
 	}
-	fmt.Printf("Probando contra %s (%s)...\n", backendName, targetModel)
+	ext := chain[0]
+	backendName := ext.Name()
+	fmt.Printf("Probando contra %s (%s)...\n", backendName, ext.Model())
 
 	for _, sc := range scenarios {
 		t.Run(sc.Name, func(t *testing.T) {
 			content := sc.ContentGenerator()
 
 			start := time.Now()
-			result := extractTasks(content, sc.Filename, sc.Subject)
+			tasks, _, err := extractWithFallback(context.Background(), cfg, chain, ExtractionRequest{
+				Content:  content,
+				Filename: sc.Filename,
+				Subject:  sc.Subject,
+				Now:      time.Now(),
+			})
 			duration := time.Since(start)
+			if err != nil {
+				t.Fatalf("%s devolvió un error: %v", backendName, err)
+			}
 
 			t.Logf("--- Escenario: %s ---", sc.Name)
 			t.Logf("Tiempo de respuesta: %v", duration)
-			t.Logf("Respuesta cruda:\n%s\n", result)
+			var lines []string
+			for _, task := range tasks {
+				lines = append(lines, task.toPendiente().Text)
+			}
+			result := strings.Join(lines, "\n")
+			t.Logf("Tareas extraídas:\n%s\n", result)
 
-			if result == "" || result == "None" {
-				t.Errorf("%s devolvió una respuesta vacía o 'None'.", backendName)
+			if len(tasks) == 0 {
+				t.Errorf("%s no devolvió ninguna tarea.", backendName)
 				return
 			}
 
@@ -117,8 +137,10 @@ Pagar el recibo de luz antes del 20.
 				}
 			}
 
-			if !strings.Contains(result, "- [ ] @{") {
-				t.Errorf("La respuesta no parece tener el formato correcto '- [ ] @{...}'.\nRespuesta: %s", result)
+			for _, task := range tasks {
+				if _, err := time.Parse(DateFormat, task.DueDate); err != nil {
+					t.Errorf("Fecha de entrega inválida %q en la tarea %+v", task.DueDate, task)
+				}
 			}
 		})
 	}
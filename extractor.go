@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TaskExtractor is an LLM backend that reads a note and answers with the
+// tasks found in it, one "- [ ] @{YYYY-MM-DD} / Materia / Descripcion" line
+// per task, or "None".
+type TaskExtractor interface {
+	// Name identifies the backend, e.g. "ollama".
+	Name() string
+	// Model is the model the backend sends requests to.
+	Model() string
+	Extract(ctx context.Context, req ExtractionRequest) (string, error)
+}
+
+// ExtractionRequest is the note, or part of a note, to extract tasks from.
+type ExtractionRequest struct {
+	Content    string
+	Filename   string
+	Subject    string
+	Now        time.Time
+	SourcePath string
+
+	// Prompts are the templates the request is rendered with; nil means
+	// those in use for Subject.
+	Prompts *promptSet
+
+	// PreviousAnswer and Problems are set on repair requests: the invalid
+	// answer is replayed and the model is asked to fix the listed problems.
+	PreviousAnswer string
+	Problems       []string
+}
+
+// extractorFactory builds a backend with the settings in cfg.
+type extractorFactory func(cfg *Config) (TaskExtractor, error)
+
+// extractorFactories holds every backend that can be selected with the
+// EXTRACTOR variable. Backends register themselves from an init function.
+var extractorFactories = map[string]extractorFactory{}
+
+func registerExtractor(name string, factory extractorFactory) {
+	if _, dup := extractorFactories[name]; dup {
+		panic("extractor registrado dos veces: " + name)
+	}
+	extractorFactories[name] = factory
+}
+
+func extractorNames() []string {
+	names := make([]string, 0, len(extractorFactories))
+	for name := range extractorFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	// unavailableLogged holds, per backend, the error last logged for it, so
+	// a backend that cannot be built is reported once and not for every
+	// note. applyConfig clears it.
+	unavailableMu     sync.Mutex
+	unavailableLogged = map[string]string{}
+)
+
+// logUnavailable logs that a backend cannot be built, unless the same error
+// was already logged; a nil err marks it as available again.
+func logUnavailable(name string, err error) {
+	unavailableMu.Lock()
+	defer unavailableMu.Unlock()
+	if err == nil {
+		delete(unavailableLogged, name)
+		return
+	}
+	if unavailableLogged[name] == err.Error() {
+		return
+	}
+	unavailableLogged[name] = err.Error()
+	log.Printf("Extractor %s no disponible: %v", name, err)
+}
+
+// extractorsFor builds the backends of a comma-separated chain with the
+// settings in cfg. They are built for every note so configuration changes
+// are picked up without restarting. A backend that cannot be built, such as
+// openai without OPENAI_BASE_URL, is left out of the chain; an unknown name
+// is an error.
+func extractorsFor(cfg *Config, spec string) ([]TaskExtractor, error) {
+	var chain []TaskExtractor
+	var errs []error
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		factory, ok := extractorFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("extractor desconocido %q (disponibles: %s)", name, strings.Join(extractorNames(), ", "))
+		}
+		ext, err := factory(cfg)
+		logUnavailable(name, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		chain = append(chain, ext)
+	}
+	if len(chain) == 0 {
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+		return nil, errors.New("no hay ningún extractor configurado")
+	}
+	return chain, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeExtractor answers every request with a fixed response and records the
+// requests it received. If answers is set, it is consumed first, one answer
+// per request.
+type fakeExtractor struct {
+	answer   string
+	answers  []string
+	err      error
+	errs     []error // returned, one per call, before err
+	requests []ExtractionRequest
+}
+
+func (f *fakeExtractor) Name() string  { return "fake" }
+func (f *fakeExtractor) Model() string { return "fake-model" }
+
+func (f *fakeExtractor) Extract(ctx context.Context, req ExtractionRequest) (string, error) {
+	f.requests = append(f.requests, req)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return "", err
+	}
+	if len(f.answers) > 0 {
+		answer := f.answers[0]
+		f.answers = f.answers[1:]
+		return answer, f.err
+	}
+	return f.answer, f.err
+}
+
+// useFakeExtractor selects f as the extraction backend for the rest of the
+// test.
+func useFakeExtractor(t *testing.T, f *fakeExtractor) {
+	t.Helper()
+	name := "fake-" + t.Name()
+	extractorFactories[name] = func(*Config) (TaskExtractor, error) { return f, nil }
+	t.Cleanup(func() { delete(extractorFactories, name) })
+	useConfig(t, func(cfg *Config) { cfg.Extractor = name })
+}
+
+func TestProcessFileWithInjectedExtractor(t *testing.T) {
+	setupTestDB(t)
+	fake := &fakeExtractor{answer: "- [ ] @{2026-10-24} / Redes / Investigar OSPF"}
+	useFakeExtractor(t, fake)
+
+	note := filepath.Join(t.TempDir(), "Redes", time.Now().Format("2006-01-02")+" Redes.md")
+	os.MkdirAll(filepath.Dir(note), 0755)
+	os.WriteFile(note, []byte("Investigar OSPF para el viernes"), 0644)
+
+	processFile(context.Background(), note)
+
+	if len(fake.requests) != 1 || fake.requests[0].Subject != "Redes" {
+		t.Fatalf("Unexpected extractor requests: %+v", fake.requests)
+	}
+	tasks, err := tasksFromSource(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ExtractedBy != "fake" || tasks[0].ExtractionModel != "fake-model" {
+		t.Errorf("Unexpected tasks: %+v", tasks)
+	}
+}
+
+func TestExtractorsForUnknownName(t *testing.T) {
+	_, err := extractorsFor(configSnapshot(), "no-existe")
+	if err == nil || !strings.Contains(err.Error(), "ollama") {
+		t.Errorf("Expected error listing available extractors, got %v", err)
+	}
+}
+
+func TestGeminiContentsSharesPrompt(t *testing.T) {
+	messages, err := buildMessages(ExtractionRequest{Content: "nota", Filename: "a.md", Subject: "Redes", Now: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	system, contents := geminiContents(messages)
+
+	if system == nil || system.Parts[0].Text != messages[0].Content || !strings.Contains(messages[0].Content, "Materia") {
+		t.Errorf("System prompt not sent as system instruction")
+	}
+	if len(contents) != len(messages)-1 {
+		t.Fatalf("Expected %d contents, got %d", len(messages)-1, len(contents))
+	}
+	if contents[1].Role != "model" || contents[1].Parts[0].Text != "None" {
+		t.Errorf("Assistant example not mapped to model role: %+v", contents[1])
+	}
+	if !strings.Contains(contents[len(contents)-1].Parts[0].Text, "nota") {
+		t.Errorf("Note content missing from last message")
+	}
+}
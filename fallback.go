@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// extractWithFallback tries each backend of chain in order and returns the
+// tasks of the first one that answers validly, along with that backend. A
+// backend that fails, times out or gives an answer that cannot be repaired
+// falls through to the next. If every backend fails, the valid part of the
+// first unrepairable answer is used, so a single configured backend behaves
+// as before; otherwise the last error is returned.
+func extractWithFallback(ctx context.Context, cfg *Config, chain []TaskExtractor, req ExtractionRequest) ([]ExtractedTask, TaskExtractor, error) {
+	var partial []ExtractedTask
+	var partialFrom TaskExtractor
+	var lastErr error
+	for i, ext := range chain {
+		tasks, err := extractNoteTasks(ctx, cfg, ext, req)
+		if err == nil {
+			return tasks, ext, nil
+		}
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+
+		var invalid *invalidAnswerError
+		if errors.As(err, &invalid) && partialFrom == nil {
+			partial, partialFrom = tasks, ext
+		}
+		lastErr = err
+		if i < len(chain)-1 {
+			log.Printf("%s falló con %s, probando con %s: %v", ext.Name(), req.Filename, chain[i+1].Name(), err)
+		}
+	}
+
+	if partialFrom != nil {
+		return partial, partialFrom, nil
+	}
+	return nil, nil, lastErr
+}
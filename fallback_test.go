@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// namedFake is a fakeExtractor with its own name, to tell backends of a chain
+// apart.
+type namedFake struct {
+	*fakeExtractor
+	name string
+}
+
+func (f namedFake) Name() string { return f.name }
+
+func TestExtractWithFallback(t *testing.T) {
+	setupTestDB(t)
+	down := namedFake{&fakeExtractor{err: errors.New("503")}, "gemini"}
+	chatty := namedFake{&fakeExtractor{answer: "Claro, aquí tienes:"}, "ollama"}
+	good := namedFake{&fakeExtractor{answer: "- [ ] @{2026-10-24} / Redes / Investigar OSPF"}, "rules"}
+
+	tasks, ext, err := extractWithFallback(context.Background(), configSnapshot(), []TaskExtractor{down, chatty, good}, ExtractionRequest{Now: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.Name() != "rules" || len(tasks) != 1 {
+		t.Errorf("Expected the task from the last backend, got %+v from %s", tasks, ext.Name())
+	}
+	if len(down.requests) != 1 || len(chatty.requests) != configSnapshot().RepairAttempts+1 {
+		t.Errorf("Earlier backends were not tried: %d, %d requests", len(down.requests), len(chatty.requests))
+	}
+
+	_, _, err = extractWithFallback(context.Background(), configSnapshot(), []TaskExtractor{chatty, down}, ExtractionRequest{Now: time.Now()})
+	if err != nil {
+		t.Errorf("Unrepairable answer should be kept when every backend fails, got %v", err)
+	}
+	_, _, err = extractWithFallback(context.Background(), configSnapshot(), []TaskExtractor{down}, ExtractionRequest{Now: time.Now()})
+	if err == nil {
+		t.Error("Expected the backend error when the whole chain fails")
+	}
+}
+
+func TestProcessFileRecordsFallbackBackend(t *testing.T) {
+	setupTestDB(t)
+	down := &fakeExtractor{err: errors.New("cuota agotada")}
+	good := &fakeExtractor{answer: "- [ ] @{2026-10-24} / Redes / Investigar OSPF"}
+	extractorFactories["fake-down"] = func(*Config) (TaskExtractor, error) { return namedFake{down, "gemini"}, nil }
+	extractorFactories["fake-good"] = func(*Config) (TaskExtractor, error) { return namedFake{good, "ollama"}, nil }
+	defer func() {
+		delete(extractorFactories, "fake-down")
+		delete(extractorFactories, "fake-good")
+	}()
+	useConfig(t, func(cfg *Config) { cfg.Extractor = "fake-down, fake-good" })
+
+	note := filepath.Join(t.TempDir(), "Redes", time.Now().Format("2006-01-02")+" Redes.md")
+	os.MkdirAll(filepath.Dir(note), 0755)
+	os.WriteFile(note, []byte("Investigar OSPF"), 0644)
+	processFile(context.Background(), note)
+
+	tasks, err := tasksFromSource(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ExtractedBy != "ollama" {
+		t.Errorf("Task was not attributed to the fallback backend: %+v", tasks)
+	}
+}
+
+func TestUnavailableExtractorIsLoggedOnce(t *testing.T) {
+	restoreConfig(t)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	cfg := builtinConfig
+	cfg.Extractor = "openai,rules"
+	cfg.OpenAI.URL = ""
+	applyConfig(cfg)
+	for i := 0; i < 3; i++ {
+		snapshot := configSnapshot()
+		if _, err := extractorsFor(snapshot, snapshot.Extractor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(buf.String(), "Extractor openai no disponible"); n != 1 {
+		t.Errorf("Expected the unavailable backend to be logged once, got %d times:\n%s", n, buf.String())
+	}
+
+	// Una recarga de la configuración lo vuelve a informar.
+	applyConfig(cfg)
+	snapshot := configSnapshot()
+	extractorsFor(snapshot, snapshot.Extractor)
+	if n := strings.Count(buf.String(), "Extractor openai no disponible"); n != 2 {
+		t.Errorf("Expected a new log line after reloading, got %d", n)
+	}
+}
+
+func TestExtractorsForSkipsUnavailable(t *testing.T) {
+	cfg := *configSnapshot()
+	cfg.OpenAI.URL = ""
+	chain, err := extractorsFor(&cfg, "openai,ollama")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 1 || chain[0].Name() != "ollama" {
+		t.Errorf("Unexpected chain: %+v", chain)
+	}
+}
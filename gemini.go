@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+func init() {
+	registerExtractor("gemini", func(cfg *Config) (TaskExtractor, error) {
+		return &geminiExtractor{model: cfg.Gemini.Model}, nil
+	})
+}
+
+// geminiExtractor calls the Google Gemini API. The client reads the API key
+// from GEMINI_API_KEY.
+type geminiExtractor struct {
+	model string
+}
+
+func (g *geminiExtractor) Name() string  { return "gemini" }
+func (g *geminiExtractor) Model() string { return g.model }
+
+// geminiContents converts the shared chat into Gemini's format: the system
+// message becomes the system instruction and assistant turns use the model
+// role.
+func geminiContents(messages []Message) (*genai.Content, []*genai.Content) {
+	var system *genai.Content
+	var contents []*genai.Content
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			system = genai.NewContentFromText(m.Content, genai.RoleUser)
+		case "assistant":
+			contents = append(contents, genai.NewContentFromText(m.Content, genai.RoleModel))
+		default:
+			contents = append(contents, genai.NewContentFromText(m.Content, genai.RoleUser))
+		}
+	}
+	return system, contents
+}
+
+func (g *geminiExtractor) Extract(ctx context.Context, req ExtractionRequest) (string, error) {
+	// El cliente toma la API KEY de la variable de entorno GEMINI_API_KEY por defecto si config es nil
+	client, err := genai.NewClient(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creando cliente Gemini: %w", err)
+	}
+
+	messages, err := buildMessages(req)
+	if err != nil {
+		return "", err
+	}
+	system, contents := geminiContents(messages)
+	result, err := client.Models.GenerateContent(ctx, g.model, contents, &genai.GenerateContentConfig{
+		SystemInstruction: system,
+	})
+	if err != nil {
+		return "", geminiError(fmt.Errorf("error llamando a Gemini API: %w", err))
+	}
+
+	return result.Text(), nil
+}
+
+// geminiTaskSchema mirrors taskListSchema in Gemini's schema type.
+var geminiTaskSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"tasks": {
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"due_date":    {Type: genai.TypeString, Description: "Fecha de entrega en formato YYYY-MM-DD"},
+					"subject":     {Type: genai.TypeString},
+					"description": {Type: genai.TypeString},
+					"confidence":  {Type: genai.TypeNumber},
+				},
+				Required:         []string{"due_date", "subject", "description", "confidence"},
+				PropertyOrdering: []string{"due_date", "subject", "description", "confidence"},
+			},
+		},
+	},
+	Required: []string{"tasks"},
+}
+
+func (g *geminiExtractor) ExtractJSON(ctx context.Context, req ExtractionRequest) (string, error) {
+	client, err := genai.NewClient(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creando cliente Gemini: %w", err)
+	}
+
+	messages, err := buildStructuredMessages(req)
+	if err != nil {
+		return "", err
+	}
+	system, contents := geminiContents(messages)
+	result, err := client.Models.GenerateContent(ctx, g.model, contents, &genai.GenerateContentConfig{
+		SystemInstruction: system,
+		ResponseMIMEType:  "application/json",
+		ResponseSchema:    geminiTaskSchema,
+	})
+	if err != nil {
+		return "", geminiError(fmt.Errorf("error llamando a Gemini API: %w", err))
+	}
+
+	return result.Text(), nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ignoreFileName is the file with .gitignore-style patterns that can be put
+// in any folder of a notes root. Its patterns apply to that folder and the
+// ones below it.
+const ignoreFileName = ".tareasignore"
+
+// ignoreRule is one parsed pattern. base is the folder, relative to the root,
+// of the ignore file it came from; empty for patterns from the configuration.
+type ignoreRule struct {
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnorePattern parses a line with .gitignore syntax: "#" starts a
+// comment, "!" re-includes what an earlier pattern excluded, a trailing "/"
+// matches only folders, and a pattern with a slash other than a trailing one
+// is matched from base instead of against any name below it. "**" matches
+// any number of folders. ok is false for blank lines and comments.
+func parseIgnorePattern(line, base string) (rule ignoreRule, ok bool, err error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return ignoreRule{}, false, nil
+	}
+	rule.base = base
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false, fmt.Errorf("patrón vacío")
+	}
+
+	expr := "^(?:.*/)?"
+	if strings.Contains(line, "/") {
+		expr = "^"
+		line = strings.TrimPrefix(line, "/")
+	}
+	glob, err := globToRegexp(line)
+	if err != nil {
+		return ignoreRule{}, false, err
+	}
+	rule.re, err = regexp.Compile(expr + glob + "$")
+	if err != nil {
+		return ignoreRule{}, false, fmt.Errorf("patrón inválido %q: %w", line, err)
+	}
+	return rule, true, nil
+}
+
+// globToRegexp translates a .gitignore glob into a regular expression.
+func globToRegexp(glob string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		atStart := i == 0 || glob[i-1] == '/'
+		switch c := glob[i]; {
+		case atStart && strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case atStart && glob[i:] == "**":
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("patrón inválido %q: falta ]", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String(), nil
+}
+
+// match reports whether rel, a path relative to the root, matches the rule.
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	return r.re.MatchString(rel)
+}
+
+// ignoreFile is a parsed ignore file, kept until the file changes.
+type ignoreFile struct {
+	modTime time.Time
+	size    int64
+	rules   []ignoreRule
+}
+
+// ignoreMatcher decides which files and folders of a notes root the scan and
+// the watcher leave alone. As in git, the last matching pattern wins, deeper
+// ignore files override shallower ones, and nothing below an ignored folder
+// can be re-included.
+type ignoreMatcher struct {
+	root  string
+	rules []ignoreRule
+
+	mu    sync.Mutex
+	files map[string]ignoreFile
+}
+
+// newIgnoreMatcher returns the matcher of root for the given configuration
+// patterns. Invalid patterns, which validate reports, are skipped.
+func newIgnoreMatcher(root string, patterns []string) *ignoreMatcher {
+	m := &ignoreMatcher{root: root, files: map[string]ignoreFile{}}
+	for _, pattern := range patterns {
+		if rule, ok, err := parseIgnorePattern(pattern, ""); ok && err == nil {
+			m.rules = append(m.rules, rule)
+		}
+	}
+	return m
+}
+
+// ignores reports whether path, a folder if isDir, is ignored.
+func (m *ignoreMatcher) ignores(path string, isDir bool) bool {
+	rel, err := filepath.Rel(m.root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	elems := strings.Split(filepath.ToSlash(rel), "/")
+
+	// Cada carpeta en el camino se revisa antes de leer el archivo de
+	// reglas que contiene.
+	rules := append([]ignoreRule(nil), m.rules...)
+	for i := range elems {
+		rules = append(rules, m.fileRules(strings.Join(elems[:i], "/"))...)
+		sub := strings.Join(elems[:i+1], "/")
+		last := i == len(elems)-1
+		if matchRules(rules, sub, isDir || !last) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRules(rules []ignoreRule, rel string, isDir bool) bool {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].match(rel, isDir) {
+			return !rules[i].negate
+		}
+	}
+	return false
+}
+
+// fileRules returns the rules of the ignore file in dir, relative to the root,
+// reading it again if it changed since the last time.
+func (m *ignoreMatcher) fileRules(dir string) []ignoreRule {
+	path := filepath.Join(m.root, filepath.FromSlash(dir), ignoreFileName)
+	info, err := os.Stat(path)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		delete(m.files, dir)
+		return nil
+	}
+	if f, ok := m.files[dir]; ok && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+		return f.rules
+	}
+	rules, err := readIgnoreFile(path, dir)
+	if err != nil {
+		log.Printf("No se pudo leer %s: %v", path, err)
+	}
+	m.files[dir] = ignoreFile{modTime: info.ModTime(), size: info.Size(), rules: rules}
+	return rules
+}
+
+// readIgnoreFile parses the ignore file at path. Invalid lines are logged and
+// skipped.
+func readIgnoreFile(path, base string) ([]ignoreRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		rule, ok, err := parseIgnorePattern(scanner.Text(), base)
+		if err != nil {
+			log.Printf("%s:%d: %v, se omite", path, n, err)
+			continue
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, scanner.Err()
+}
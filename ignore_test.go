@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(ignoreFileName, "# Carpetas de la bóveda\nadjuntos/\n/borradores\n*.tmp.md\n!importante.tmp.md\n**/viejo/**\n")
+	write("Redes/"+ignoreFileName, "practicas/*.md\n!practicas/final.md\n")
+	m := newIgnoreMatcher(root, []string{".obsidian/", "plantillas"})
+
+	for rel, want := range map[string]bool{
+		".obsidian":                       true,
+		"Redes/.obsidian/app.json":        true,
+		"plantillas":                      true,
+		"Redes/plantillas/clase.md":       true,
+		"adjuntos/diagrama.md":            true,
+		"Redes/adjuntos/a.md":             true,
+		"borradores/a.md":                 true,
+		"Redes/borradores/a.md":           false,
+		"Redes/nota.tmp.md":               true,
+		"Redes/importante.tmp.md":         false,
+		"Algebra/viejo/2025-03-01.md":     true,
+		"Redes/practicas/p1.md":           true,
+		"Redes/practicas/final.md":        false,
+		"Redes/practicas/otra/p2.md":      false,
+		"Algebra/practicas/p1.md":         false,
+		"Redes/2026-10-17 Clase.md":       false,
+		"Redes de Computadoras/adjuntos/": true,
+	} {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		isDir := filepath.Ext(rel) != ".md" && filepath.Ext(rel) != ".json"
+		if got := m.ignores(path, isDir); got != want {
+			t.Errorf("ignores(%q) = %v, want %v", rel, got, want)
+		}
+	}
+
+	// Un archivo editado se vuelve a leer.
+	write("Redes/"+ignoreFileName, "# vacío\n")
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(root, "Redes", ignoreFileName), future, future)
+	if m.ignores(filepath.Join(root, "Redes", "practicas", "p1.md"), false) {
+		t.Error("Changes to an ignore file were not picked up")
+	}
+}
+
+func TestParseIgnorePatternErrors(t *testing.T) {
+	for _, pattern := range []string{"[adjuntos", "/", "!"} {
+		if _, _, err := parseIgnorePattern(pattern, ""); err == nil {
+			t.Errorf("Expected error for %q", pattern)
+		}
+	}
+	for _, line := range []string{"", "   ", "# comentario"} {
+		if _, ok, err := parseIgnorePattern(line, ""); ok || err != nil {
+			t.Errorf("Expected %q to be skipped, got %v, %v", line, ok, err)
+		}
+	}
+}
+
+func TestScanAndWatcherHonorIgnoreFiles(t *testing.T) {
+	setupTestDB(t)
+	restoreConfig(t)
+	dir := t.TempDir()
+	cfg := builtinConfig
+	cfg.Extractor = "rules"
+	cfg.Roots = []RootConfig{{Path: dir}}
+	applyConfig(cfg)
+	root := configSnapshot().roots[0]
+
+	date := time.Now().Format("2006-01-02")
+	for _, folder := range []string{"Redes", ".obsidian", "Redes/archivo"} {
+		os.MkdirAll(filepath.Join(dir, folder), 0755)
+		os.WriteFile(filepath.Join(dir, folder, date+" Nota.md"), []byte("- [ ] Tarea en "+folder+"\n"), 0644)
+	}
+	os.WriteFile(filepath.Join(dir, "Redes", ignoreFileName), []byte("archivo/\n"), 0644)
+
+	scanAndProcessDirectory(context.Background(), dir)
+	tasks, err := getTasksFromDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Description != "Tarea en Redes" {
+		t.Fatalf("Expected only the task outside ignored folders, got %+v", tasks)
+	}
+
+	processed := make(chan string, 10)
+	nw, err := newNoteWatcher(dir, 50*time.Millisecond, root.ignores, func(path string) { processed <- path })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Close()
+	go nw.run()
+
+	os.WriteFile(filepath.Join(dir, ".obsidian", "workspace.md"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(dir, "Redes", "archivo", date+" Otra.md"), []byte("- [ ] Otra\n"), 0644)
+	select {
+	case path := <-processed:
+		t.Fatalf("Ignored note %s was processed", path)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// Al quitar la regla, la carpeta se vigila y sus notas se procesan.
+	os.WriteFile(filepath.Join(dir, "Redes", ignoreFileName), []byte("# nada\n"), 0644)
+	want := filepath.Join(dir, "Redes", "archivo", date+" Otra.md")
+	for {
+		select {
+		case path := <-processed:
+			if path == want {
+				return
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timed out waiting for %s", want)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Migration is a numbered change to the SQLite schema. Migrations are applied
+// in order of Version, each one in its own transaction, and recorded in the
+// schema_migrations table so they run exactly once per database.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in the order it must be applied. New
+// migrations are appended at the end with the next version number; existing
+// entries must never be edited once released.
+var migrations = []Migration{
+	{Version: 1, Name: "create_tasks", Up: createTasksTable},
+	{Version: 2, Name: "task_structured_columns", Up: addTaskStructuredColumns},
+	{Version: 3, Name: "task_listing_indexes", Up: createTaskListingIndexes},
+	{Version: 4, Name: "task_events", Up: createTaskEventsTable},
+	{Version: 5, Name: "processed_files", Up: createProcessedFilesTable},
+	{Version: 6, Name: "task_sources", Up: addTaskSources},
+	{Version: 7, Name: "task_provenance", Up: addTaskProvenance},
+	{Version: 8, Name: "task_confidence", Up: addTaskConfidence},
+	{Version: 9, Name: "quarantined_responses", Up: createQuarantinedResponsesTable},
+	{Version: 10, Name: "retry_queue", Up: createRetryQueueTable},
+	{Version: 11, Name: "extraction_cache", Up: createExtractionCacheTable},
+	{Version: 12, Name: "task_prompt_version", Up: addTaskPromptVersion},
+	{Version: 13, Name: "task_sort_indexes", Up: createTaskSortIndexes},
+}
+
+// MigrationStatus describes whether a migration has been applied to the
+// current database.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+func ensureMigrationsTable() error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func appliedMigrations() (map[int]time.Time, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error querying schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAtStr string
+		if err := rows.Scan(&version, &appliedAtStr); err != nil {
+			return nil, fmt.Errorf("error scanning schema_migrations row: %w", err)
+		}
+		appliedAt, err := time.Parse(TimeFormat, appliedAtStr)
+		if err != nil {
+			log.Printf("Error parsing applied_at for migration %d: %v", version, err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// migrationStatus reports every known migration together with the time it
+// was applied, if it was.
+func migrationStatus() ([]MigrationStatus, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Migration: m}
+		if t, ok := applied[m.Version]; ok {
+			s.AppliedAt = &t
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// applyMigrations runs every pending migration and returns how many were
+// applied. It stops at the first failure, leaving that migration's
+// transaction rolled back.
+func applyMigrations() (int, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		log.Printf("Aplicando migración %d (%s)...", m.Version, m.Name)
+		if err := applyMigration(m); err != nil {
+			return count, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func applyMigration(m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.Up(tx); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO schema_migrations(version, name, applied_at) VALUES(?, ?, ?)",
+		m.Version, m.Name, time.Now().Format(TimeFormat))
+	if err != nil {
+		return fmt.Errorf("error recording migration: %w", err)
+	}
+	return tx.Commit()
+}
+
+// tableColumns returns the set of column names of table. Migrations use it to
+// stay idempotent on databases that were altered by hand before versioning
+// existed.
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s schema: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("error scanning %s schema: %w", table, err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// addColumns adds each column definition whose name is not yet present in
+// table.
+func addColumns(tx *sql.Tx, table string, defs ...string) error {
+	existing, err := tableColumns(tx, table)
+	if err != nil {
+		return err
+	}
+	for _, def := range defs {
+		if existing[strings.Fields(def)[0]] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, def)); err != nil {
+			return fmt.Errorf("error adding column %s to %s: %w", def, table, err)
+		}
+	}
+	return nil
+}
+
+func createTasksTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS tasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		text TEXT NOT NULL,
+		checked BOOLEAN NOT NULL DEFAULT FALSE,
+		completed_at TEXT
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating tasks table: %w", err)
+	}
+	return nil
+}
+
+// addTaskStructuredColumns adds the due_date, subject and description columns
+// and back-fills them by parsing the text of every existing task.
+func addTaskStructuredColumns(tx *sql.Tx) error {
+	if err := addColumns(tx, "tasks", "due_date TEXT", "subject TEXT", "description TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return backfillTaskColumns(tx)
+}
+
+// backfillTaskColumns parses the text of every task that has no description
+// yet and stores the result in the structured columns.
+func backfillTaskColumns(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, text FROM tasks WHERE description = ''")
+	if err != nil {
+		return fmt.Errorf("error querying tasks for backfill: %w", err)
+	}
+	type taskText struct {
+		id   int
+		text string
+	}
+	var pending []taskText
+	for rows.Next() {
+		var t taskText
+		if err := rows.Scan(&t.id, &t.text); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning task for backfill: %w", err)
+		}
+		pending = append(pending, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating tasks for backfill: %w", err)
+	}
+
+	stmt, err := tx.Prepare("UPDATE tasks SET due_date = ?, subject = ?, description = ? WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("error preparing backfill statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, t := range pending {
+		dueDate, subject, description := parseTaskText(t.text)
+		if _, err := stmt.Exec(nullString(dueDate), nullString(subject), description, t.id); err != nil {
+			return fmt.Errorf("error backfilling task %d: %w", t.id, err)
+		}
+	}
+	if len(pending) > 0 {
+		log.Printf("Columnas rellenadas para %d tareas existentes.", len(pending))
+	}
+	return nil
+}
+
+// createTaskListingIndexes backs the filters and sort orders of GET /tasks.
+func createTaskListingIndexes(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_tasks_due_date ON tasks(due_date, id);
+	CREATE INDEX IF NOT EXISTS idx_tasks_subject ON tasks(subject COLLATE NOCASE, id);
+	CREATE INDEX IF NOT EXISTS idx_tasks_checked ON tasks(checked, id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating task indexes: %w", err)
+	}
+	return nil
+}
+
+// createTaskEventsTable stores the change feed replayed to SSE clients that
+// reconnect with Last-Event-ID.
+func createTaskEventsTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS task_events (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		task_id INTEGER NOT NULL,
+		payload TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating task_events table: %w", err)
+	}
+	return nil
+}
+
+// createProcessedFilesTable tracks which notes have been sent to the LLM so
+// the notes themselves no longer need to be rewritten.
+func createProcessedFilesTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS processed_files (
+		path TEXT PRIMARY KEY,
+		content_hash TEXT NOT NULL,
+		mtime TEXT NOT NULL,
+		processed_at TEXT NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating processed_files table: %w", err)
+	}
+	return nil
+}
+
+// addTaskSources links each extracted task to the note and section it came
+// from, and records the section hashes of every processed note so edits can
+// be re-extracted incrementally.
+func addTaskSources(tx *sql.Tx) error {
+	if err := addColumns(tx, "tasks", "source_path TEXT", "source_section TEXT", "removed_from_source BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_tasks_source_path ON tasks(source_path);
+	CREATE TABLE IF NOT EXISTS note_sections (
+		path TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		PRIMARY KEY (path, hash)
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating note_sections table: %w", err)
+	}
+	return nil
+}
+
+// addTaskProvenance records where, when and by which model each task was
+// extracted.
+func addTaskProvenance(tx *sql.Tx) error {
+	return addColumns(tx, "tasks",
+		"source_subject TEXT",
+		"note_date TEXT",
+		"extracted_by TEXT",
+		"extraction_model TEXT",
+		"source_snippet TEXT",
+	)
+}
+
+// addTaskConfidence stores the confidence the model reported for each task.
+func addTaskConfidence(tx *sql.Tx) error {
+	return addColumns(tx, "tasks", "confidence REAL")
+}
+
+// createQuarantinedResponsesTable keeps LLM answers that could not be repaired
+// so they can be reviewed by hand.
+func createQuarantinedResponsesTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS quarantined_responses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source_path TEXT,
+		backend TEXT NOT NULL,
+		model TEXT,
+		content TEXT NOT NULL,
+		response TEXT NOT NULL,
+		problems TEXT NOT NULL,
+		attempts INTEGER NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating quarantined_responses table: %w", err)
+	}
+	return nil
+}
+
+// createRetryQueueTable holds notes whose extraction failed so they are tried
+// again, even across restarts.
+func createRetryQueueTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS retry_queue (
+		path TEXT PRIMARY KEY,
+		attempts INTEGER NOT NULL,
+		last_error TEXT NOT NULL,
+		next_attempt_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_retry_queue_next_attempt ON retry_queue(next_attempt_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating retry_queue table: %w", err)
+	}
+	return nil
+}
+
+// createExtractionCacheTable stores validated extractions so identical
+// requests are not sent to the backend again.
+func createExtractionCacheTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS extraction_cache (
+		content_hash TEXT NOT NULL,
+		backend TEXT NOT NULL,
+		model TEXT NOT NULL,
+		prompt_version TEXT NOT NULL,
+		tasks TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (content_hash, backend, model, prompt_version)
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating extraction_cache table: %w", err)
+	}
+	return nil
+}
+
+// addTaskPromptVersion records which prompt templates each task was
+// extracted with.
+func addTaskPromptVersion(tx *sql.Tx) error {
+	return addColumns(tx, "tasks", "prompt_version TEXT")
+}
+
+// createTaskSortIndexes indexes the exact expressions GET /tasks sorts on
+// (see sortKeys), one per direction, so a page is read in order from the
+// index instead of sorting the whole table.
+func createTaskSortIndexes(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_tasks_due_date_asc ON tasks(COALESCE(due_date, '9999-12-31'), id);
+	CREATE INDEX IF NOT EXISTS idx_tasks_due_date_desc ON tasks(COALESCE(due_date, ''), id);
+	CREATE INDEX IF NOT EXISTS idx_tasks_subject_asc ON tasks(COALESCE(subject, char(1114111)), id);
+	CREATE INDEX IF NOT EXISTS idx_tasks_subject_desc ON tasks(COALESCE(subject, ''), id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating task sort indexes: %w", err)
+	}
+	return nil
+}
+
+// runMigrateCommand implements the "migrate status" and "migrate up"
+// subcommands.
+func runMigrateCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("uso: tareasgenerador migrate status|up")
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pendiente"
+			if s.AppliedAt != nil {
+				state = "aplicada " + s.AppliedAt.Format(TimeFormat)
+			}
+			fmt.Printf("%4d  %-28s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	case "up":
+		count, err := applyMigrations()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migraciones aplicadas.\n", count)
+		return nil
+	default:
+		return fmt.Errorf("subcomando de migrate desconocido: %s", args[0])
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyMigrationsUpgradesLegacyDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tasks.db")
+
+	// Base de datos creada por una versión anterior, sin schema_migrations.
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = legacy.Exec(`
+	CREATE TABLE tasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		text TEXT NOT NULL,
+		checked BOOLEAN NOT NULL DEFAULT FALSE,
+		completed_at TEXT
+	);
+	INSERT INTO tasks(text, checked) VALUES ('@{2026-03-01} / Redes / Configurar VLAN 10', 0);
+	INSERT INTO tasks(text, checked) VALUES ('Comprar leche', 1);
+	`)
+	legacy.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mutex.Lock()
+	err = initDB(dbPath)
+	mutex.Unlock()
+	if err != nil {
+		t.Fatalf("initDB: %v", err)
+	}
+	defer db.Close()
+
+	tasks, err := getTasksFromDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("Expected 2 tasks, got %d", len(tasks))
+	}
+	// ORDER BY id DESC
+	if tasks[1].DueDate != "2026-03-01" || tasks[1].Subject != "Redes" || tasks[1].Description != "Configurar VLAN 10" {
+		t.Errorf("Legacy task was not back-filled: %+v", tasks[1])
+	}
+	if tasks[0].Subject != "" || tasks[0].Description != "Comprar leche" {
+		t.Errorf("Unstructured task was not back-filled: %+v", tasks[0])
+	}
+
+	statuses, err := migrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("Migration %d (%s) was not applied", s.Version, s.Name)
+		}
+	}
+
+	count, err := applyMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no pending migrations on second run, applied %d", count)
+	}
+}
+
+func TestMigrationVersionsAreSequential(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migrations[%d] has version %d, want %d", i, m.Version, i+1)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type OllamaRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	Format   any       `json:"format,omitempty"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type OllamaResponse struct {
+	Message Message `json:"message"`
+}
+
+func init() {
+	registerExtractor("ollama", func(cfg *Config) (TaskExtractor, error) {
+		return &ollamaExtractor{url: cfg.Ollama.URL, model: cfg.Ollama.Model, client: http.DefaultClient}, nil
+	})
+}
+
+// ollamaExtractor talks to Ollama's /api/chat endpoint.
+type ollamaExtractor struct {
+	url    string
+	model  string
+	client *http.Client
+}
+
+func (o *ollamaExtractor) Name() string  { return "ollama" }
+func (o *ollamaExtractor) Model() string { return o.model }
+
+func (o *ollamaExtractor) Extract(ctx context.Context, req ExtractionRequest) (string, error) {
+	messages, err := buildMessages(req)
+	if err != nil {
+		return "", err
+	}
+	return o.chat(ctx, messages, nil)
+}
+
+// ExtractJSON passes taskListSchema as Ollama's format so the answer is
+// constrained to it.
+func (o *ollamaExtractor) ExtractJSON(ctx context.Context, req ExtractionRequest) (string, error) {
+	messages, err := buildStructuredMessages(req)
+	if err != nil {
+		return "", err
+	}
+	return o.chat(ctx, messages, taskListSchema)
+}
+
+func (o *ollamaExtractor) chat(ctx context.Context, messages []Message, format any) (string, error) {
+	reqBody := OllamaRequest{
+		Model:    o.model,
+		Stream:   false,
+		Messages: messages,
+		Format:   format,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("error codificando petición a Ollama: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("error creando petición a Ollama: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return "", &retryableError{err: fmt.Errorf("error conectando con Ollama: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp, fmt.Errorf("Ollama respondió %s", resp.Status))
+	}
+
+	var ollamaResp OllamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
+		return "", fmt.Errorf("error decodificando respuesta de Ollama: %w", err)
+	}
+
+	return ollamaResp.Message.Content, nil
+}
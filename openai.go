@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type ChatCompletionRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat asks the server for JSON matching a schema.
+type ResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema struct {
+		Name   string `json:"name"`
+		Strict bool   `json:"strict"`
+		Schema any    `json:"schema"`
+	} `json:"json_schema"`
+}
+
+type ChatCompletionResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func init() {
+	registerExtractor("openai", func(cfg *Config) (TaskExtractor, error) {
+		if cfg.OpenAI.URL == "" {
+			return nil, errors.New("OPENAI_BASE_URL no definido")
+		}
+		ext := &openaiExtractor{
+			baseURL: strings.TrimSuffix(cfg.OpenAI.URL, "/"),
+			model:   cfg.OpenAI.Model,
+			apiKey:  cfg.OpenAI.APIKey,
+			client:  http.DefaultClient,
+		}
+		if !cfg.OpenAI.StructuredOutput {
+			return ext, nil
+		}
+		return &openaiJSONExtractor{ext}, nil
+	})
+}
+
+// openaiExtractor speaks the OpenAI chat-completions protocol, as served by
+// llama.cpp's llama-server, vLLM, LM Studio and OpenAI itself.
+type openaiExtractor struct {
+	baseURL string
+	model   string
+	apiKey  string
+	client  *http.Client
+}
+
+func (o *openaiExtractor) Name() string  { return "openai" }
+func (o *openaiExtractor) Model() string { return o.model }
+
+func (o *openaiExtractor) Extract(ctx context.Context, req ExtractionRequest) (string, error) {
+	messages, err := buildMessages(req)
+	if err != nil {
+		return "", err
+	}
+	return o.complete(ctx, ChatCompletionRequest{Messages: messages})
+}
+
+// openaiJSONExtractor adds structured output through response_format. Not
+// every server honors it, so it can be turned off with
+// OPENAI_STRUCTURED_OUTPUT=false, which leaves the plain markdown extractor.
+type openaiJSONExtractor struct {
+	*openaiExtractor
+}
+
+func (o *openaiJSONExtractor) ExtractJSON(ctx context.Context, req ExtractionRequest) (string, error) {
+	messages, err := buildStructuredMessages(req)
+	if err != nil {
+		return "", err
+	}
+	format := &ResponseFormat{Type: "json_schema"}
+	format.JSONSchema.Name = "tasks"
+	format.JSONSchema.Strict = true
+	format.JSONSchema.Schema = taskListSchema
+	return o.complete(ctx, ChatCompletionRequest{Messages: messages, ResponseFormat: format})
+}
+
+func (o *openaiExtractor) complete(ctx context.Context, reqBody ChatCompletionRequest) (string, error) {
+	reqBody.Model = o.model
+	reqBody.Stream = false
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("error codificando petición chat completions: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("error creando petición chat completions: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return "", &retryableError{err: fmt.Errorf("error conectando con %s: %w", o.baseURL, err)}
+	}
+	defer resp.Body.Close()
+
+	var chatResp ChatCompletionResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&chatResp)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && chatResp.Error != nil {
+			return "", statusError(resp, fmt.Errorf("chat completions respondió %s: %s", resp.Status, chatResp.Error.Message))
+		}
+		return "", statusError(resp, fmt.Errorf("chat completions respondió %s", resp.Status))
+	}
+	if decodeErr != nil {
+		return "", fmt.Errorf("error decodificando respuesta chat completions: %w", decodeErr)
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", errors.New("respuesta chat completions sin choices")
+	}
+
+	return chatResp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenAIExtractor(t *testing.T) {
+	var got ChatCompletionRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secreto" {
+			t.Errorf("Unexpected Authorization header %q", auth)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "- [ ] @{2026-10-24} / Redes / Investigar OSPF"}}]}`))
+	}))
+	defer ts.Close()
+
+	cfg := *configSnapshot()
+	cfg.OpenAI.URL, cfg.OpenAI.Model, cfg.OpenAI.APIKey = ts.URL+"/v1/", "qwen2.5-7b-instruct", "secreto"
+
+	ext, err := extractorFactories["openai"](&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := ext.Extract(context.Background(), ExtractionRequest{Content: "Investigar OSPF", Filename: "a.md", Subject: "Redes", Now: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(tasks, "Investigar OSPF") {
+		t.Errorf("Unexpected answer %q", tasks)
+	}
+
+	if got.Model != "qwen2.5-7b-instruct" || got.Stream {
+		t.Errorf("Unexpected request: model=%q stream=%v", got.Model, got.Stream)
+	}
+	want, _ := buildMessages(ExtractionRequest{Content: "Investigar OSPF", Filename: "a.md", Subject: "Redes", Now: time.Now()})
+	if len(got.Messages) != len(want) || got.Messages[0].Content != want[0].Content {
+		t.Errorf("Few-shot messages were not reused: %+v", got.Messages)
+	}
+}
+
+func TestOpenAIExtractorStructured(t *testing.T) {
+	setupTestDB(t)
+	var got ChatCompletionRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "{\"tasks\": [{\"due_date\": \"2026-10-24\", \"subject\": \"Redes\", \"description\": \"Investigar OSPF\", \"confidence\": 0.9}]}"}}]}`))
+	}))
+	defer ts.Close()
+
+	cfg := *configSnapshot()
+	cfg.OpenAI.URL, cfg.OpenAI.StructuredOutput = ts.URL, true
+
+	ext, err := extractorFactories["openai"](&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := extractNoteTasks(context.Background(), &cfg, ext, ExtractionRequest{Now: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Description != "Investigar OSPF" {
+		t.Errorf("Unexpected tasks: %+v", tasks)
+	}
+	if got.ResponseFormat == nil || got.ResponseFormat.Type != "json_schema" || !strings.Contains(got.Messages[0].Content, `"confidence"`) {
+		t.Errorf("Structured output was not requested: %+v", got.ResponseFormat)
+	}
+
+	// Con OPENAI_STRUCTURED_OUTPUT=false se usa la respuesta markdown.
+	cfg.OpenAI.StructuredOutput = false
+	ext, err = extractorFactories["openai"](&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ext.(StructuredExtractor); ok {
+		t.Errorf("Structured output was not turned off")
+	}
+}
+
+func TestOpenAIExtractorErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": {"message": "model not found"}}`))
+	}))
+	defer ts.Close()
+
+	ext := &openaiExtractor{baseURL: ts.URL, model: "x", client: http.DefaultClient}
+	_, err := ext.Extract(context.Background(), ExtractionRequest{Now: time.Now()})
+	if err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Errorf("Expected server error message, got %v", err)
+	}
+
+	cfg := *configSnapshot()
+	cfg.OpenAI.URL = ""
+	if _, err := extractorFactories["openai"](&cfg); err == nil {
+		t.Error("Expected error without OPENAI_BASE_URL")
+	}
+}
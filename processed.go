@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const processedMarker = "procesado_por_ia: true"
+
+// processedFile is the row of processed_files for one note. Notes are
+// re-extracted when their content hash changes.
+type processedFile struct {
+	Path        string
+	ContentHash string
+	ModTime     time.Time
+	ProcessedAt time.Time
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func getProcessedFile(path string) (processedFile, bool, error) {
+	var pf processedFile
+	var modTime, processedAt string
+	err := db.QueryRow("SELECT path, content_hash, mtime, processed_at FROM processed_files WHERE path = ?", path).
+		Scan(&pf.Path, &pf.ContentHash, &modTime, &processedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pf, false, nil
+	}
+	if err != nil {
+		return pf, false, fmt.Errorf("error querying processed file %s: %w", path, err)
+	}
+	pf.ModTime, _ = time.Parse(time.RFC3339Nano, modTime)
+	pf.ProcessedAt, _ = time.Parse(TimeFormat, processedAt)
+	return pf, true, nil
+}
+
+func upsertProcessedFile(pf processedFile) error {
+	_, err := db.Exec(`
+	INSERT INTO processed_files(path, content_hash, mtime, processed_at) VALUES(?, ?, ?, ?)
+	ON CONFLICT(path) DO UPDATE SET content_hash = excluded.content_hash, mtime = excluded.mtime, processed_at = excluded.processed_at
+	`, pf.Path, pf.ContentHash, pf.ModTime.Format(time.RFC3339Nano), pf.ProcessedAt.Format(TimeFormat))
+	if err != nil {
+		return fmt.Errorf("error recording processed file %s: %w", pf.Path, err)
+	}
+	return nil
+}
+
+// shouldProcessNote decides whether the note needs extraction. Notes marked
+// in their frontmatter by earlier versions are recorded as processed the first
+// time they are seen, so upgrading does not re-extract the whole tree.
+// Callers must hold mutex.
+func shouldProcessNote(cfg *Config, path string, info os.FileInfo, content []byte) (bool, error) {
+	if cfg.FrontmatterMarker {
+		return !strings.Contains(string(content), processedMarker), nil
+	}
+
+	_, known, err := getProcessedFile(path)
+	if err != nil {
+		return false, err
+	}
+	if !known && strings.Contains(string(content), processedMarker) {
+		return false, recordProcessedNote(path, info, content)
+	}
+	unchanged, err := noteUnchanged(path, info, content)
+	return !unchanged, err
+}
+
+func recordProcessedNote(path string, info os.FileInfo, content []byte) error {
+	return upsertProcessedFile(processedFile{
+		Path:        path,
+		ContentHash: contentHash(content),
+		ModTime:     info.ModTime(),
+		ProcessedAt: time.Now(),
+	})
+}
+
+// noteUnchanged reports whether the note at path was already processed with
+// exactly this content. The mtime is checked first so unchanged notes are not
+// re-hashed on every scan. Callers must hold mutex.
+func noteUnchanged(path string, info os.FileInfo, content []byte) (bool, error) {
+	pf, ok, err := getProcessedFile(path)
+	if err != nil || !ok {
+		return false, err
+	}
+	if pf.ModTime.Equal(info.ModTime()) {
+		return true, nil
+	}
+	if pf.ContentHash != contentHash(content) {
+		return false, nil
+	}
+	// Mismo contenido con otra fecha (p. ej. tras una sincronización):
+	// actualizamos la mtime para no volver a calcular el hash.
+	pf.ModTime = info.ModTime()
+	return true, upsertProcessedFile(pf)
+}
+
+// addFrontmatterMarker adds the processed marker to the note's YAML
+// frontmatter, creating the block only if the note does not have one, so
+// Obsidian notes never end up with two --- blocks.
+func addFrontmatterMarker(content string) string {
+	if rest, ok := strings.CutPrefix(content, "---\n"); ok {
+		if end := strings.Index(rest, "\n---"); end != -1 || strings.HasPrefix(rest, "---") {
+			return "---\n" + processedMarker + "\n" + rest
+		}
+	}
+	return metadataHeader + content
+}
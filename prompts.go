@@ -0,0 +1,222 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+//go:embed prompts/default.tmpl
+var defaultPrompts string
+
+// promptSets caches the parsed templates by directory and subject until the
+// configuration is reloaded, so every request of a note uses the same ones.
+var (
+	promptSetsMu sync.Mutex
+	promptSets   = map[[2]string]*promptSet{}
+)
+
+// resetPromptSets makes the next loadPromptSet read the templates again.
+func resetPromptSets() {
+	promptSetsMu.Lock()
+	clear(promptSets)
+	promptSetsMu.Unlock()
+}
+
+// promptSet is the parsed prompt templates for one subject. Version is the
+// declared "version" template plus a hash of every source file, so editing a
+// template always yields a new version even if nobody bumps the number.
+type promptSet struct {
+	tmpl    *template.Template
+	Version string
+}
+
+// promptData is what the templates can use.
+type promptData struct {
+	Subject  string
+	Date     string
+	Weekday  string
+	Filename string
+	Content  string
+	Problems []string
+}
+
+// loadPromptSet returns the templates for subject: the embedded defaults and
+// then the overrides in dir. Blocks defined in an override replace the
+// default ones; the rest are kept. Edits are picked up when the
+// configuration is reloaded.
+func loadPromptSet(dir, subject string) (*promptSet, error) {
+	key := [2]string{dir, subject}
+	promptSetsMu.Lock()
+	defer promptSetsMu.Unlock()
+	if ps, ok := promptSets[key]; ok {
+		return ps, nil
+	}
+	ps, err := parsePromptSet(dir, subject)
+	if err != nil {
+		return nil, err
+	}
+	promptSets[key] = ps
+	return ps, nil
+}
+
+func parsePromptSet(dir, subject string) (*promptSet, error) {
+	tmpl, err := template.New("default.tmpl").Parse(defaultPrompts)
+	if err != nil {
+		return nil, fmt.Errorf("error en los prompts por defecto: %w", err)
+	}
+	sources := []string{defaultPrompts}
+
+	if dir != "" {
+		files := []string{"default.tmpl"}
+		if name := filepath.Base(subject); subject != "" && name != "." && name != string(filepath.Separator) {
+			files = append(files, name+".tmpl")
+		}
+		for _, name := range files {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error leyendo prompt %s: %w", name, err)
+			}
+			if tmpl, err = tmpl.New(name).Parse(string(data)); err != nil {
+				return nil, fmt.Errorf("error en el prompt %s: %w", name, err)
+			}
+			sources = append(sources, string(data))
+		}
+	}
+
+	ps := &promptSet{tmpl: tmpl}
+	declared, err := ps.render("version", promptData{})
+	if err != nil {
+		return nil, err
+	}
+	ps.Version = declared + "-" + contentHash([]byte(strings.Join(sources, "\x00")))[:8]
+	return ps, nil
+}
+
+func (ps *promptSet) render(name string, data promptData) (string, error) {
+	var b strings.Builder
+	if err := ps.tmpl.ExecuteTemplate(&b, name, data); err != nil {
+		return "", fmt.Errorf("error generando el prompt %q: %w", name, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func newPromptData(req ExtractionRequest) promptData {
+	return promptData{
+		Subject:  req.Subject,
+		Date:     req.Now.Format(DateFormat),
+		Weekday:  req.Now.Weekday().String(),
+		Filename: req.Filename,
+		Content:  req.Content,
+		Problems: req.Problems,
+	}
+}
+
+// messages builds the chat for req: the system prompt, the few-shot examples
+// example_1, example_2, ... with their answers, the note itself and, on
+// repair requests, the invalid answer followed by the repair prompt. With
+// structured set, the JSON variants of the system prompt and answers are used.
+func (ps *promptSet) messages(req ExtractionRequest, structured bool) ([]Message, error) {
+	system, answerSuffix := "system", "_answer"
+	if structured {
+		system, answerSuffix = "system_json", "_answer_json"
+	}
+	data := newPromptData(req)
+
+	type part struct{ role, name string }
+	parts := []part{{"system", system}}
+	for n := 1; ps.tmpl.Lookup(fmt.Sprintf("example_%d", n)) != nil; n++ {
+		example := fmt.Sprintf("example_%d", n)
+		parts = append(parts, part{"user", example}, part{"assistant", example + answerSuffix})
+	}
+	parts = append(parts, part{"user", "user"})
+
+	var msgs []Message
+	for _, p := range parts {
+		content, err := ps.render(p.name, data)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, Message{Role: p.role, Content: content})
+	}
+
+	if req.PreviousAnswer != "" {
+		repair, err := ps.render("repair", data)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs,
+			Message{Role: "assistant", Content: req.PreviousAnswer},
+			Message{Role: "user", Content: repair},
+		)
+	}
+	return msgs, nil
+}
+
+// prompts returns the templates req is rendered with: req.Prompts, which
+// processNote loads once per note, or else those in use for req's subject.
+func (req ExtractionRequest) prompts() (*promptSet, error) {
+	if req.Prompts != nil {
+		return req.Prompts, nil
+	}
+	return loadPromptSet(configSnapshot().PromptsDir, req.Subject)
+}
+
+// buildMessages returns the full chat sent to every backend for req's
+// subject.
+func buildMessages(req ExtractionRequest) ([]Message, error) {
+	ps, err := req.prompts()
+	if err != nil {
+		return nil, err
+	}
+	return ps.messages(req, false)
+}
+
+// buildStructuredMessages is buildMessages for backends with structured
+// output: the same few-shot examples, answered in JSON.
+func buildStructuredMessages(req ExtractionRequest) ([]Message, error) {
+	ps, err := req.prompts()
+	if err != nil {
+		return nil, err
+	}
+	return ps.messages(req, true)
+}
+
+// currentPromptVersions returns the prompt version in use for every subject
+// with its own override, keyed by subject; "" is the default.
+func currentPromptVersions() (map[string]string, error) {
+	dir := configSnapshot().PromptsDir
+
+	subjects := []string{""}
+	if dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("error leyendo %s: %w", dir, err)
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if !e.IsDir() && strings.HasSuffix(name, ".tmpl") && name != "default.tmpl" {
+				subjects = append(subjects, strings.TrimSuffix(name, ".tmpl"))
+			}
+		}
+	}
+
+	versions := map[string]string{}
+	for _, subject := range subjects {
+		ps, err := loadPromptSet(dir, subject)
+		if err != nil {
+			return nil, err
+		}
+		versions[subject] = ps.Version
+	}
+	return versions, nil
+}
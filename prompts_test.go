@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setPromptsDir(t *testing.T, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	useConfig(t, func(cfg *Config) { cfg.PromptsDir = dir })
+}
+
+func TestDefaultPromptMessages(t *testing.T) {
+	req := ExtractionRequest{Content: "Investigar OSPF", Filename: "a.md", Subject: "Redes", Now: time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)}
+	messages, err := buildMessages(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// system, dos ejemplos con sus respuestas y la nota.
+	if len(messages) != 6 || messages[0].Role != "system" || messages[5].Role != "user" {
+		t.Fatalf("Unexpected messages: %+v", messages)
+	}
+	last := messages[5].Content
+	if !strings.Contains(last, "Investigar OSPF") || !strings.Contains(last, "2026-10-17") || !strings.Contains(last, "Redes") {
+		t.Errorf("User prompt is missing request data: %q", last)
+	}
+
+	structured, err := buildStructuredMessages(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(structured[2].Content, `"tasks"`) {
+		t.Errorf("Structured example answer is not JSON: %q", structured[2].Content)
+	}
+
+	req.PreviousAnswer, req.Problems = "basura", []string{"línea 1: sin fecha"}
+	repair, err := buildMessages(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repair) != 8 || repair[6].Content != "basura" || !strings.Contains(repair[7].Content, "línea 1: sin fecha") {
+		t.Errorf("Unexpected repair messages: %+v", repair[6:])
+	}
+}
+
+func TestPromptOverrides(t *testing.T) {
+	base, err := loadPromptSet(configSnapshot().PromptsDir, "Redes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setPromptsDir(t, map[string]string{
+		"default.tmpl": `{{define "system"}}Sistema propio{{end}}`,
+		"Redes.tmpl":   `{{define "user"}}Nota de {{.Subject}}: {{.Content}}{{end}}`,
+	})
+
+	messages, err := buildMessages(ExtractionRequest{Content: "OSPF", Subject: "Redes", Now: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messages[0].Content != "Sistema propio" || messages[len(messages)-1].Content != "Nota de Redes: OSPF" {
+		t.Errorf("Overrides not applied: %+v", messages)
+	}
+	if len(messages) != 6 {
+		t.Errorf("Default examples were lost: %d messages", len(messages))
+	}
+
+	other, err := buildMessages(ExtractionRequest{Content: "OSPF", Subject: "Algebra", Now: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other[0].Content != "Sistema propio" || strings.HasPrefix(other[len(other)-1].Content, "Nota de") {
+		t.Errorf("Subject override leaked into another subject: %+v", other)
+	}
+
+	redes, err := loadPromptSet(configSnapshot().PromptsDir, "Redes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redes.Version == base.Version || !strings.HasPrefix(redes.Version, "1-") {
+		t.Errorf("Version did not change with the templates: %q vs %q", redes.Version, base.Version)
+	}
+	versions, err := currentPromptVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions["Redes"] != redes.Version {
+		t.Errorf("Unexpected versions: %v", versions)
+	}
+}
+
+func TestPromptOverrideErrors(t *testing.T) {
+	setPromptsDir(t, map[string]string{"Redes.tmpl": `{{define "user"}}{{.Nope}}{{end}}`})
+	if _, err := buildMessages(ExtractionRequest{Subject: "Redes", Now: time.Now()}); err == nil {
+		t.Error("Expected error for a template using an unknown field")
+	}
+
+	setPromptsDir(t, map[string]string{"default.tmpl": `{{define "system"}}{{end`})
+	if _, err := loadPromptSet(configSnapshot().PromptsDir, ""); err == nil {
+		t.Error("Expected error for a malformed template")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuarantinedResponse is an LLM answer that still failed validation after
+// every repair attempt, kept for manual review.
+type QuarantinedResponse struct {
+	ID         int
+	SourcePath string
+	Backend    string
+	Model      string
+	Content    string
+	Response   string
+	Problems   []string
+	Attempts   int
+	CreatedAt  time.Time
+}
+
+// quarantineResponse stores q. Callers must hold mutex.
+func quarantineResponse(q QuarantinedResponse) error {
+	_, err := db.Exec(`INSERT INTO quarantined_responses(source_path, backend, model, content, response, problems, attempts, created_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
+		nullString(q.SourcePath), q.Backend, nullString(q.Model), q.Content, q.Response,
+		strings.Join(q.Problems, "\n"), q.Attempts, time.Now().Format(TimeFormat))
+	if err != nil {
+		return fmt.Errorf("error inserting quarantined response: %w", err)
+	}
+	return nil
+}
+
+// quarantinedResponses returns every quarantined answer, oldest first.
+func quarantinedResponses() ([]QuarantinedResponse, error) {
+	rows, err := db.Query(`SELECT id, source_path, backend, model, content, response, problems, attempts, created_at
+		FROM quarantined_responses ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying quarantined responses: %w", err)
+	}
+	defer rows.Close()
+
+	var list []QuarantinedResponse
+	for rows.Next() {
+		var q QuarantinedResponse
+		var sourcePath, model sql.NullString
+		var problems, createdAt string
+		if err := rows.Scan(&q.ID, &sourcePath, &q.Backend, &model, &q.Content, &q.Response, &problems, &q.Attempts, &createdAt); err != nil {
+			return nil, fmt.Errorf("error scanning quarantined response: %w", err)
+		}
+		q.SourcePath, q.Model = sourcePath.String, model.String
+		q.Problems = strings.Split(problems, "\n")
+		q.CreatedAt, _ = time.Parse(TimeFormat, createdAt)
+		list = append(list, q)
+	}
+	return list, rows.Err()
+}
+
+// deleteQuarantinedResponse removes a reviewed entry.
+func deleteQuarantinedResponse(id int) error {
+	res, err := db.Exec("DELETE FROM quarantined_responses WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("error deleting quarantined response: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no existe la respuesta en cuarentena %d", id)
+	}
+	return nil
+}
+
+// runQuarantineCommand implements the "quarantine list" and
+// "quarantine delete <id>" subcommands.
+func runQuarantineCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: tareasgenerador quarantine list|delete <id>")
+	}
+
+	switch args[0] {
+	case "list":
+		list, err := quarantinedResponses()
+		if err != nil {
+			return err
+		}
+		for _, q := range list {
+			fmt.Printf("#%d  %s  %s/%s  %s (%d intentos)\n", q.ID, q.CreatedAt.Format(TimeFormat), q.Backend, q.Model, q.SourcePath, q.Attempts)
+			for _, p := range q.Problems {
+				fmt.Printf("    - %s\n", p)
+			}
+			fmt.Printf("    Respuesta:\n%s\n\n", q.Response)
+		}
+		fmt.Printf("%d respuestas en cuarentena.\n", len(list))
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("uso: tareasgenerador quarantine delete <id>")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("id inválido %q", args[1])
+		}
+		return deleteQuarantinedResponse(id)
+	default:
+		return fmt.Errorf("subcomando de quarantine desconocido: %s", args[0])
+	}
+}
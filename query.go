@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 500
+)
+
+// TaskFilter holds the query parameters accepted by GET /tasks. Every field is
+// optional; the zero value lists all tasks newest first.
+type TaskFilter struct {
+	Checked    *bool
+	Subject    string
+	DueBefore  string
+	DueAfter   string
+	Sort       string
+	Descending bool
+	Limit      int
+	Cursor     *taskCursor
+}
+
+// taskCursor marks the last task of a page. Key is the value of the sort
+// column for that task, so the next page can resume with a keyset query;
+// Sort is the sort parameter it was made for, "-" prefix included.
+type taskCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k,omitempty"`
+	ID   int    `json:"id"`
+}
+
+// TaskPage is the response envelope of GET /tasks.
+type TaskPage struct {
+	Tasks      []Pendiente `json:"tasks"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// sortKeys maps the public sort names to the SQL expression ordered on, for
+// ascending and descending order. Tasks without a due date always go last.
+// The task_sort_indexes migration indexes these same expressions.
+var sortKeys = map[string][2]string{
+	"id":       {"", ""},
+	"due_date": {"COALESCE(due_date, '9999-12-31')", "COALESCE(due_date, '')"},
+	"subject":  {"COALESCE(subject, char(1114111))", "COALESCE(subject, '')"},
+}
+
+func encodeCursor(c taskCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*taskCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("cursor inválido")
+	}
+	var c taskCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("cursor inválido")
+	}
+	return &c, nil
+}
+
+func parseDateParam(q url.Values, name string) (string, error) {
+	v := q.Get(name)
+	if v == "" {
+		return "", nil
+	}
+	if _, err := time.Parse(DateFormat, v); err != nil {
+		return "", fmt.Errorf("%s debe tener el formato YYYY-MM-DD: %q", name, v)
+	}
+	return v, nil
+}
+
+// parseTaskFilter validates the listing query parameters.
+func parseTaskFilter(q url.Values) (TaskFilter, error) {
+	f := TaskFilter{Sort: "id", Descending: true, Limit: defaultPageSize}
+
+	if v := q.Get("checked"); v != "" {
+		checked, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("checked debe ser true o false: %q", v)
+		}
+		f.Checked = &checked
+	}
+	f.Subject = q.Get("subject")
+
+	var err error
+	if f.DueBefore, err = parseDateParam(q, "due_before"); err != nil {
+		return f, err
+	}
+	if f.DueAfter, err = parseDateParam(q, "due_after"); err != nil {
+		return f, err
+	}
+
+	if v := q.Get("sort"); v != "" {
+		f.Descending = strings.HasPrefix(v, "-")
+		f.Sort = strings.TrimPrefix(v, "-")
+		if _, ok := sortKeys[f.Sort]; !ok {
+			return f, fmt.Errorf("sort no soportado: %q", v)
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return f, fmt.Errorf("limit debe ser un entero positivo: %q", v)
+		}
+		f.Limit = min(limit, maxPageSize)
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		if f.Cursor, err = decodeCursor(v); err != nil {
+			return f, err
+		}
+		if f.Cursor.Sort != f.sortParam() {
+			return f, fmt.Errorf("el cursor es de sort=%s, no de sort=%s", f.Cursor.Sort, f.sortParam())
+		}
+	}
+	return f, nil
+}
+
+// sortParam returns the sort as written in the query string.
+func (f TaskFilter) sortParam() string {
+	if f.Descending {
+		return "-" + f.Sort
+	}
+	return f.Sort
+}
+
+// taskListQuery builds the filtered, sorted and paginated query described by
+// f. keyed is true when the sort key is selected after the task columns.
+func taskListQuery(f TaskFilter) (query string, args []any, keyed bool) {
+	var where []string
+
+	if f.Checked != nil {
+		where = append(where, "checked = ?")
+		args = append(args, *f.Checked)
+	}
+	if f.Subject != "" {
+		where = append(where, "subject = ? COLLATE NOCASE")
+		args = append(args, f.Subject)
+	}
+	if f.DueBefore != "" {
+		where = append(where, "due_date < ?")
+		args = append(args, f.DueBefore)
+	}
+	if f.DueAfter != "" {
+		where = append(where, "due_date >= ?")
+		args = append(args, f.DueAfter)
+	}
+
+	op, dir, keyExpr := ">", "ASC", sortKeys[f.Sort][0]
+	if f.Descending {
+		op, dir, keyExpr = "<", "DESC", sortKeys[f.Sort][1]
+	}
+
+	if f.Cursor != nil {
+		if keyExpr == "" {
+			where = append(where, "id "+op+" ?")
+			args = append(args, f.Cursor.ID)
+		} else {
+			// La primera condición deja que SQLite empiece a leer el índice
+			// desde el cursor en lugar de recorrerlo desde el principio.
+			where = append(where, fmt.Sprintf("%[1]s %[2]s= ? AND (%[1]s %[2]s ? OR id %[2]s ?)", keyExpr, op))
+			args = append(args, f.Cursor.Key, f.Cursor.Key, f.Cursor.ID)
+		}
+	}
+
+	query = "SELECT " + taskColumns
+	if keyExpr != "" {
+		query += ", " + keyExpr
+	}
+	query += " FROM tasks"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if keyExpr != "" {
+		query += fmt.Sprintf(" ORDER BY %s %s, id %s", keyExpr, dir, dir)
+	} else {
+		query += " ORDER BY id " + dir
+	}
+	query += " LIMIT ?"
+	// Pedimos una fila extra para saber si hay otra página.
+	args = append(args, f.Limit+1)
+	return query, args, keyExpr != ""
+}
+
+// listTasksFromDB runs the query described by f. It returns the cursor for
+// the next page, or "" on the last page.
+func listTasksFromDB(f TaskFilter) ([]Pendiente, string, error) {
+	query, args, keyed := taskListQuery(f)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("error querying tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := []Pendiente{}
+	var keys []string
+	for rows.Next() {
+		var p Pendiente
+		var key string
+		if keyed {
+			p, err = scanTask(keyedRow{rows, &key})
+		} else {
+			p, err = scanTask(rows)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("error scanning task row: %w", err)
+		}
+		tasks = append(tasks, p)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating tasks: %w", err)
+	}
+
+	if len(tasks) <= f.Limit {
+		return tasks, "", nil
+	}
+	tasks = tasks[:f.Limit]
+	last := tasks[len(tasks)-1]
+	return tasks, encodeCursor(taskCursor{Sort: f.sortParam(), Key: keys[f.Limit-1], ID: last.ID}), nil
+}
+
+// keyedRow scans the trailing sort key column after the task columns.
+type keyedRow struct {
+	rowScanner
+	key *string
+}
+
+func (r keyedRow) Scan(dest ...any) error {
+	return r.rowScanner.Scan(append(dest, r.key)...)
+}
+
+func listTasksHandler(w http.ResponseWriter, r *http.Request) {
+	f, err := parseTaskFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	tasks, next, err := listTasksFromDB(f)
+	if err != nil {
+		log.Printf("Error al listar tareas: %v", err)
+		http.Error(w, fmt.Sprintf("Error al obtener las tareas: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, TaskPage{Tasks: tasks, NextCursor: next})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func seedTasks(t *testing.T, texts ...string) {
+	t.Helper()
+	for _, text := range texts {
+		if _, err := insertTaskIntoDB(Pendiente{Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listDescriptions(t *testing.T, query string) ([]string, string) {
+	t.Helper()
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parseTaskFilter(q)
+	if err != nil {
+		t.Fatalf("parseTaskFilter(%q): %v", query, err)
+	}
+	tasks, next, err := listTasksFromDB(f)
+	if err != nil {
+		t.Fatalf("listTasksFromDB(%q): %v", query, err)
+	}
+	var descriptions []string
+	for _, p := range tasks {
+		descriptions = append(descriptions, p.Description)
+	}
+	return descriptions, next
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListTasksFilters(t *testing.T) {
+	setupTestDB(t)
+	seedTasks(t,
+		"@{2026-10-20} / Redes / A",
+		"@{2026-11-05} / Redes / B",
+		"@{2026-10-01} / BasesDeDatos / C",
+		"Sin fecha D",
+	)
+	if err := updateTaskInDB(1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Sin fecha D", "C", "B", "A"}},
+		{"checked=false", []string{"Sin fecha D", "C", "B"}},
+		{"subject=redes", []string{"B", "A"}},
+		{"due_before=2026-11-01", []string{"C", "A"}},
+		{"due_after=2026-10-15&sort=due_date", []string{"A", "B"}},
+		{"sort=due_date", []string{"C", "A", "B", "Sin fecha D"}},
+		{"sort=-due_date", []string{"B", "A", "C", "Sin fecha D"}},
+		{"sort=id", []string{"A", "B", "C", "Sin fecha D"}},
+	}
+	for _, c := range cases {
+		got, next := listDescriptions(t, c.query)
+		if !equalStrings(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.query, got, c.want)
+		}
+		if next != "" {
+			t.Errorf("%q: unexpected next cursor on single page", c.query)
+		}
+	}
+}
+
+func TestListTasksPagination(t *testing.T) {
+	setupTestDB(t)
+	seedTasks(t,
+		"@{2026-10-03} / Redes / A",
+		"@{2026-10-01} / Redes / B",
+		"Sin fecha C",
+		"@{2026-10-01} / Redes / D",
+		"@{2026-10-02} / Redes / E",
+	)
+
+	for _, sort := range []string{"due_date", "-due_date", "-id", "subject"} {
+		all, _ := listDescriptions(t, "sort="+sort)
+
+		var paged []string
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > len(all) {
+				t.Fatalf("sort=%s: pagination did not terminate", sort)
+			}
+			page, next := listDescriptions(t, "limit=2&sort="+sort+"&cursor="+cursor)
+			paged = append(paged, page...)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if !equalStrings(paged, all) {
+			t.Errorf("sort=%s: paged %v, unpaged %v", sort, paged, all)
+		}
+	}
+}
+
+func TestListTasksHandler(t *testing.T) {
+	setupTestDB(t)
+	seedTasks(t, "@{2026-10-20} / Redes / A", "@{2026-10-21} / Redes / B")
+
+	rec := doRequest(t, newRouter(), "GET", "/tasks?limit=1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var page TaskPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tasks) != 1 || page.NextCursor == "" {
+		t.Errorf("Expected one task and a next cursor, got %+v", page)
+	}
+
+	for _, query := range []string{"checked=maybe", "due_before=ayer", "sort=random", "limit=0", "cursor=!!"} {
+		rec := doRequest(t, newRouter(), "GET", "/tasks?"+query, "")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestListTasksSortsUseIndexes(t *testing.T) {
+	setupTestDB(t)
+	seedTasks(t, "@{2026-10-03} / Redes / A", "Sin fecha B")
+
+	for _, sort := range []string{"due_date", "-due_date", "subject", "-subject"} {
+		q, _ := url.ParseQuery("sort=" + sort)
+		f, err := parseTaskFilter(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Cursor = &taskCursor{Sort: sort, Key: "2026-10-01", ID: 1}
+		query, args, _ := taskListQuery(f)
+		rows, err := db.Query("EXPLAIN QUERY PLAN "+query, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var plan []string
+		for rows.Next() {
+			var id, parent, notused int
+			var detail string
+			rows.Scan(&id, &parent, &notused, &detail)
+			plan = append(plan, detail)
+		}
+		rows.Close()
+		joined := strings.Join(plan, "; ")
+		if !strings.Contains(joined, "SEARCH tasks USING INDEX idx_tasks_") || strings.Contains(joined, "TEMP B-TREE") {
+			t.Errorf("sort=%s does not resume from the cursor in an index: %s", sort, joined)
+		}
+	}
+}
+
+func TestListTasksRejectsCursorOfOtherSort(t *testing.T) {
+	setupTestDB(t)
+	seedTasks(t, "@{2026-10-03} / Redes / A", "@{2026-10-01} / Redes / B")
+
+	_, next := listDescriptions(t, "limit=1&sort=id")
+	for _, sort := range []string{"due_date", "-id"} {
+		q, _ := url.ParseQuery("sort=" + sort + "&cursor=" + next)
+		if _, err := parseTaskFilter(q); err == nil {
+			t.Errorf("Expected a sort=id cursor to be rejected with sort=%s", sort)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configReloadDebounce groups the several events an editor produces when it
+// saves the configuration file or a prompt template.
+var configReloadDebounce = 500 * time.Millisecond
+
+// notesService runs the watchers and periodic scans of the configured note
+// roots and reloads the configuration when it changes, while the HTTP server
+// keeps serving.
+type notesService struct {
+	ctx context.Context
+	// configPath is the file given with -config; empty means
+	// defaultConfigPath.
+	configPath string
+
+	mu       sync.Mutex
+	watchers []*noteWatcher
+}
+
+// roots returns the note roots in use.
+func (s *notesService) roots() []RootConfig {
+	return configSnapshot().roots
+}
+
+// restartWatchers replaces the note watchers with new ones for the roots in
+// use. Notes the old watchers were waiting on are handed to the new ones.
+func (s *notesService) restartWatchers() {
+	cfg := configSnapshot()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []string
+	for _, w := range s.watchers {
+		pending = append(pending, w.pending()...)
+		w.Close()
+	}
+	s.watchers = nil
+
+	if len(cfg.roots) == 0 {
+		log.Println("ADVERTENCIA: no hay directorios de notas configurados (DIRECTORIO_NOTAS o roots en la configuración)")
+	}
+	for _, root := range cfg.roots {
+		w, err := newNoteWatcher(root.Path, cfg.WatchDebounce, root.ignores, func(path string) {
+			notePool.Go(func() { processFile(s.ctx, path) })
+		})
+		if err != nil {
+			log.Printf("No se pudo vigilar %s, solo se usará el escaneo periódico: %v", root.Path, err)
+			continue
+		}
+		go w.run()
+		s.watchers = append(s.watchers, w)
+		log.Printf("Vigilando cambios en %s", root.Path)
+	}
+
+	for _, path := range pending {
+		root := cfg.rootFor(path)
+		for _, w := range s.watchers {
+			if w.root == root.Path && !root.ignores(path, false) {
+				w.schedule(path)
+			}
+		}
+	}
+}
+
+// scanAll scans every note root.
+func (s *notesService) scanAll() {
+	for _, root := range s.roots() {
+		scanAndProcessDirectory(s.ctx, root.Path)
+	}
+}
+
+// close stops the note watchers.
+func (s *notesService) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, w := range s.watchers {
+		w.Close()
+	}
+	s.watchers = nil
+}
+
+// reload loads the configuration again and, if it is valid, swaps it for the
+// one in use. Notes being processed finish with the settings they started
+// with. An invalid file is logged and the previous configuration kept. Roots
+// that are new are scanned right away.
+func (s *notesService) reload() {
+	cfg, err := loadConfig(s.configPath)
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err != nil {
+		log.Printf("Configuración no recargada, se mantiene la anterior: %v", err)
+		return
+	}
+
+	known := map[string]bool{}
+	for _, root := range s.roots() {
+		known[filepath.Clean(root.Path)] = true
+	}
+
+	applyConfig(cfg)
+	log.Println("Configuración recargada")
+
+	s.restartWatchers()
+	for _, root := range s.roots() {
+		if !known[filepath.Clean(root.Path)] {
+			go scanAndProcessDirectory(s.ctx, root.Path)
+		}
+	}
+}
+
+// watchConfig reloads the configuration on SIGHUP and whenever the
+// configuration file or a prompt template changes, until ctx is done.
+func (s *notesService) watchConfig() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	configFile := s.configPath
+	if configFile == "" {
+		configFile = defaultConfigPath()
+	}
+	if configFile != "" {
+		configFile, _ = filepath.Abs(configFile)
+	}
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("No se pudo vigilar la configuración, usa SIGHUP para recargarla: %v", err)
+	} else {
+		defer fsw.Close()
+		events, errs = fsw.Events, fsw.Errors
+	}
+
+	// Se vigilan los directorios y no los archivos porque muchos editores
+	// guardan escribiendo un archivo nuevo y renombrándolo.
+	var watched []string
+	watchDirs := func() {
+		if fsw == nil {
+			return
+		}
+		for _, dir := range watched {
+			fsw.Remove(dir)
+		}
+		watched = nil
+		dirs := []string{configSnapshot().PromptsDir}
+		if configFile != "" {
+			dirs = append(dirs, filepath.Dir(configFile))
+		}
+		for _, dir := range dirs {
+			if dir == "" || dir == "." {
+				continue
+			}
+			if err := fsw.Add(dir); err != nil {
+				log.Printf("No se pudo vigilar %s: %v", dir, err)
+				continue
+			}
+			watched = append(watched, dir)
+		}
+	}
+	relevant := func(name string) bool {
+		name, _ = filepath.Abs(name)
+		if name == configFile {
+			return true
+		}
+		dir := configSnapshot().PromptsDir
+		if dir == "" || !strings.HasSuffix(name, ".tmpl") {
+			return false
+		}
+		dir, _ = filepath.Abs(dir)
+		return filepath.Dir(name) == dir
+	}
+	watchDirs()
+
+	changed := make(chan struct{}, 1)
+	var timer *time.Timer
+	for {
+		select {
+		case <-hup:
+			log.Println("SIGHUP recibido, recargando la configuración...")
+			s.reload()
+			watchDirs()
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if !relevant(ev.Name) {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(configReloadDebounce, func() {
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				})
+			} else {
+				timer.Reset(configReloadDebounce)
+			}
+		case <-changed:
+			log.Println("La configuración cambió, recargando...")
+			s.reload()
+			watchDirs()
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			log.Printf("Error vigilando la configuración: %v", err)
+		case <-s.ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReload(t *testing.T) {
+	restoreConfig(t)
+	a, b := t.TempDir(), t.TempDir()
+	path := writeConfig(t, "config.toml", "extractor = \"rules\"\n[[roots]]\npath = \""+a+"\"\n")
+	notes := &notesService{ctx: context.Background(), configPath: path}
+	defer notes.close()
+
+	notes.reload()
+	if roots := notes.roots(); len(roots) != 1 || roots[0].Path != a || configSnapshot().Extractor != "rules" {
+		t.Fatalf("Config not applied: %+v, %s", roots, configSnapshot().Extractor)
+	}
+
+	// Un archivo inválido no reemplaza la configuración en uso.
+	os.WriteFile(path, []byte("extractor = \"chatgpt\"\n"), 0644)
+	notes.reload()
+	if roots := notes.roots(); len(roots) != 1 || configSnapshot().Extractor != "rules" {
+		t.Errorf("Invalid config was applied: %+v, %s", roots, configSnapshot().Extractor)
+	}
+
+	os.WriteFile(path, []byte("extractor = \"rules\"\n[[roots]]\npath = \""+b+"\"\n"), 0644)
+	notes.reload()
+	if roots := notes.roots(); len(roots) != 1 || roots[0].Path != b {
+		t.Errorf("Roots not swapped: %+v", roots)
+	}
+	if len(notes.watchers) != 1 || notes.watchers[0].root != b {
+		t.Errorf("Watchers not restarted for the new root")
+	}
+}
+
+func TestReloadDoesNotWaitForNotesInFlight(t *testing.T) {
+	setupTestDB(t)
+	restoreConfig(t)
+
+	started, unblock := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(started) })
+		<-unblock
+		json.NewEncoder(w).Encode(ollamaAnswer(ExtractedTask{DueDate: "2026-10-20", Subject: "Redes", Description: "Configurar VLAN 10", Confidence: 0.9}))
+	}))
+	defer ts.Close()
+	var unblockOnce sync.Once
+	release := func() { unblockOnce.Do(func() { close(unblock) }) }
+	defer release()
+
+	dir := t.TempDir()
+	cfg := builtinConfig
+	cfg.Extractor = "ollama"
+	cfg.Ollama.URL, cfg.Ollama.Model = ts.URL, "modelo-viejo"
+	cfg.Roots = []RootConfig{{Path: dir}}
+	applyConfig(cfg)
+
+	os.MkdirAll(filepath.Join(dir, "Redes"), 0755)
+	note := filepath.Join(dir, "Redes", time.Now().Format("2006-01-02")+" Clase.md")
+	os.WriteFile(note, []byte("Hay que configurar la VLAN 10\n"), 0644)
+	done := make(chan struct{})
+	go func() {
+		processFile(context.Background(), note)
+		close(done)
+	}()
+	<-started
+
+	path := writeConfig(t, "config.toml", "extractor = \"rules\"\n[[roots]]\npath = \""+dir+"\"\n")
+	notes := &notesService{ctx: context.Background(), configPath: path}
+	defer notes.close()
+	reloaded := make(chan struct{})
+	go func() {
+		notes.reload()
+		close(reloaded)
+	}()
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("Reload waited for the note being extracted")
+	}
+	if configSnapshot().Extractor != "rules" {
+		t.Errorf("Config not applied: %s", configSnapshot().Extractor)
+	}
+
+	// La nota en curso termina con la configuración con la que empezó.
+	release()
+	<-done
+	mutex.RLock()
+	tasks, err := tasksFromSource(note)
+	mutex.RUnlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ExtractedBy != "ollama" || tasks[0].ExtractionModel != "modelo-viejo" {
+		t.Errorf("Expected the note to keep its original settings, got %+v", tasks)
+	}
+}
+
+func TestWatchConfigReloadsOnChange(t *testing.T) {
+	setupTestDB(t)
+	restoreConfig(t)
+	oldDebounce := configReloadDebounce
+	configReloadDebounce = 20 * time.Millisecond
+	defer func() { configReloadDebounce = oldDebounce }()
+
+	a, b := t.TempDir(), t.TempDir()
+	config := func(root string) string {
+		return "extractor = \"rules\"\nwatch_debounce = \"20ms\"\n[[roots]]\npath = \"" + root + "\"\n"
+	}
+	path := writeConfig(t, "config.toml", config(a))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notes := &notesService{ctx: ctx, configPath: path}
+	defer notes.close()
+	notes.reload()
+	done := make(chan struct{})
+	go func() {
+		notes.watchConfig()
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := os.WriteFile(path, []byte(config(b)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the new root", func() bool {
+		roots := notes.roots()
+		return len(roots) == 1 && roots[0].Path == b
+	})
+
+	// El vigilante de notas ya sigue el nuevo directorio.
+	os.MkdirAll(filepath.Join(b, "Redes"), 0755)
+	note := filepath.Join(b, "Redes", time.Now().Format("2006-01-02")+" Clase.md")
+	os.WriteFile(note, []byte("- [ ] Configurar VLAN 10\n"), 0644)
+	waitFor(t, "the note in the new root", func() bool {
+		mutex.RLock()
+		defer mutex.RUnlock()
+		tasks, err := tasksFromSource(note)
+		return err == nil && len(tasks) == 1
+	})
+}
+
+func TestPromptSetsReloaded(t *testing.T) {
+	setPromptsDir(t, map[string]string{"default.tmpl": `{{define "system"}}Uno{{end}}`})
+	t.Cleanup(resetPromptSets)
+
+	before, err := loadPromptSet(configSnapshot().PromptsDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(configSnapshot().PromptsDir, "default.tmpl"), []byte(`{{define "system"}}Dos{{end}}`), 0644)
+
+	same, err := loadPromptSet(configSnapshot().PromptsDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Version != before.Version {
+		t.Error("Templates changed before a reload")
+	}
+
+	resetPromptSets()
+	after, err := loadPromptSet(configSnapshot().PromptsDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Version == before.Version {
+		t.Error("Templates not read again after a reload")
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// validateExtractedTask returns the problems with the n-th task of an answer,
+// or nil if it can be stored.
+func validateExtractedTask(n int, t ExtractedTask) []string {
+	var problems []string
+	if _, err := time.Parse(DateFormat, strings.TrimSpace(t.DueDate)); err != nil {
+		problems = append(problems, fmt.Sprintf("tarea %d: la fecha de entrega %q no tiene el formato YYYY-MM-DD", n, t.DueDate))
+	}
+	if strings.TrimSpace(t.Subject) == "" {
+		problems = append(problems, fmt.Sprintf("tarea %d: falta la materia", n))
+	}
+	if strings.TrimSpace(t.Description) == "" {
+		problems = append(problems, fmt.Sprintf("tarea %d: la descripción está vacía", n))
+	}
+	if t.Confidence < 0 || t.Confidence > 1 {
+		problems = append(problems, fmt.Sprintf("tarea %d: la confianza %v no está entre 0 y 1", n, t.Confidence))
+	}
+	return problems
+}
+
+// parseMarkdownAnswer reads an answer in the "- [ ] @{YYYY-MM-DD} / Materia /
+// Descripcion" format. Lines that are not tasks, such as a chatty preamble,
+// are reported as problems instead of being ignored.
+func parseMarkdownAnswer(answer string) ([]ExtractedTask, []string) {
+	var tasks []ExtractedTask
+	var problems []string
+	for _, line := range strings.Split(answer, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "None" {
+			continue
+		}
+		if !strings.HasPrefix(line, "- [ ]") {
+			problems = append(problems, fmt.Sprintf("línea que no es una tarea: %q", line))
+			continue
+		}
+
+		var t ExtractedTask
+		rest := strings.TrimSpace(strings.TrimPrefix(line, "- [ ]"))
+		if strings.HasPrefix(rest, "@{") {
+			if end := strings.Index(rest, "}"); end != -1 {
+				t.DueDate = strings.TrimSpace(rest[2:end])
+				rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest[end+1:]), "/"))
+			}
+		}
+		parts := strings.SplitN(rest, "/", 2)
+		if len(parts) != 2 {
+			problems = append(problems, fmt.Sprintf("faltan los separadores / en %q", line))
+			continue
+		}
+		t.Subject, t.Description = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		tasks = append(tasks, t)
+	}
+	return tasks, problems
+}
+
+// requestTasks sends req to ext and returns the raw answer, the tasks that
+// passed validation and the problems found. Failed calls are retried by
+// callBackend; err is only set when the backend could not be reached at all,
+// while an invalid answer is reported in problems.
+func requestTasks(ctx context.Context, cfg *Config, ext TaskExtractor, req ExtractionRequest) (answer string, valid []ExtractedTask, problems []string, err error) {
+	var tasks []ExtractedTask
+	if se, ok := ext.(StructuredExtractor); ok {
+		answer, err = callBackend(ctx, cfg, ext.Name(), func(ctx context.Context) (string, error) {
+			return se.ExtractJSON(ctx, req)
+		})
+		if err != nil {
+			return "", nil, nil, err
+		}
+		tasks, err = decodeStructuredTasks(answer)
+		if err != nil {
+			return answer, nil, []string{err.Error()}, nil
+		}
+	} else {
+		answer, err = callBackend(ctx, cfg, ext.Name(), func(ctx context.Context) (string, error) {
+			return ext.Extract(ctx, req)
+		})
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if answer == "" {
+			return "", nil, nil, errors.New("respuesta vacía")
+		}
+		tasks, problems = parseMarkdownAnswer(answer)
+	}
+
+	for i, t := range tasks {
+		if p := validateExtractedTask(i+1, t); len(p) > 0 {
+			problems = append(problems, p...)
+			continue
+		}
+		valid = append(valid, t)
+	}
+	return answer, valid, problems, nil
+}
+
+// invalidAnswerError is returned by extractNoteTasks when the answer could
+// not be repaired.
+type invalidAnswerError struct {
+	backend  string
+	problems []string
+}
+
+func (e *invalidAnswerError) Error() string {
+	return fmt.Sprintf("respuesta inválida de %s: %s", e.backend, strings.Join(e.problems, "; "))
+}
+
+// extractNoteTasks asks ext for the tasks in req, using structured output
+// when the backend supports it. Valid answers are cached, so the same request
+// to the same backend, model and prompt version is only sent once. Invalid
+// answers are sent back to the same backend with the validation errors up to
+// cfg.RepairAttempts times; if the last answer is still invalid it is
+// quarantined and its valid tasks are returned along with an
+// *invalidAnswerError.
+func extractNoteTasks(ctx context.Context, cfg *Config, ext TaskExtractor, req ExtractionRequest) ([]ExtractedTask, error) {
+	cache := cfg.ExtractionCache && usesPrompt(ext)
+	var key extractionCacheKey
+	var err error
+	if cache {
+		if key, err = cacheKeyFor(ext, req); err != nil {
+			return nil, err
+		}
+		mutex.RLock()
+		tasks, ok, err := cachedExtraction(key)
+		mutex.RUnlock()
+		if err != nil {
+			log.Printf("Error consultando la caché de extracciones: %v", err)
+		} else if ok {
+			log.Printf("Usando extracción en caché de %s para %s", ext.Name(), req.Filename)
+			return tasks, nil
+		}
+	}
+
+	var valid []ExtractedTask
+	var answer string
+	var problems []string
+	for attempt := 0; attempt <= cfg.RepairAttempts; attempt++ {
+		if attempt > 0 {
+			log.Printf("Respuesta inválida de %s para %s, pidiendo corrección (%d/%d): %s",
+				ext.Name(), req.Filename, attempt, cfg.RepairAttempts, strings.Join(problems, "; "))
+			req.PreviousAnswer, req.Problems = answer, problems
+		}
+
+		answer, valid, problems, err = requestTasks(ctx, cfg, ext, req)
+		if err != nil {
+			return nil, err
+		}
+		if len(problems) == 0 {
+			if cache {
+				mutex.Lock()
+				err = storeExtraction(key, valid)
+				mutex.Unlock()
+				if err != nil {
+					log.Printf("Error guardando la extracción en caché: %v", err)
+				}
+			}
+			return valid, nil
+		}
+	}
+
+	log.Printf("Respuesta de %s para %s sigue siendo inválida, se guarda en cuarentena: %s",
+		ext.Name(), req.Filename, strings.Join(problems, "; "))
+	mutex.Lock()
+	err = quarantineResponse(QuarantinedResponse{
+		SourcePath: req.SourcePath,
+		Backend:    ext.Name(),
+		Model:      ext.Model(),
+		Content:    req.Content,
+		Response:   answer,
+		Problems:   problems,
+		Attempts:   cfg.RepairAttempts + 1,
+	})
+	mutex.Unlock()
+	if err != nil {
+		log.Printf("Error guardando respuesta en cuarentena: %v", err)
+	}
+	return valid, &invalidAnswerError{backend: ext.Name(), problems: problems}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMarkdownAnswer(t *testing.T) {
+	answer := "Claro, aquí están las tareas:\n- [ ] @{2026-10-24} / Redes / Investigar OSPF\n- [ ] @{2026-10-25} Configurar VLAN\n\nNone"
+	tasks, problems := parseMarkdownAnswer(answer)
+
+	if len(tasks) != 1 || tasks[0].DueDate != "2026-10-24" || tasks[0].Subject != "Redes" || tasks[0].Description != "Investigar OSPF" {
+		t.Errorf("Unexpected tasks: %+v", tasks)
+	}
+	if len(problems) != 2 || !strings.Contains(problems[0], "Claro") || !strings.Contains(problems[1], "separadores") {
+		t.Errorf("Unexpected problems: %q", problems)
+	}
+}
+
+func TestValidateExtractedTask(t *testing.T) {
+	if p := validateExtractedTask(1, ExtractedTask{DueDate: "2026-10-24", Subject: "Redes", Description: "OSPF", Confidence: 0.5}); p != nil {
+		t.Errorf("Valid task reported problems: %q", p)
+	}
+	p := validateExtractedTask(2, ExtractedTask{DueDate: "24/10/2026", Description: " ", Confidence: 2})
+	if len(p) != 4 || !strings.HasPrefix(p[0], "tarea 2:") {
+		t.Errorf("Unexpected problems: %q", p)
+	}
+}
+
+func TestExtractNoteTasksRepairsInvalidAnswer(t *testing.T) {
+	setupTestDB(t)
+	fake := &fakeExtractor{answers: []string{
+		"- [ ] @{mañana} / Redes / Investigar OSPF",
+		"- [ ] @{2026-10-24} / Redes / Investigar OSPF",
+	}}
+	tasks, err := extractNoteTasks(context.Background(), configSnapshot(), fake, ExtractionRequest{Filename: "a.md", Now: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].DueDate != "2026-10-24" {
+		t.Errorf("Unexpected tasks: %+v", tasks)
+	}
+	if len(fake.requests) != 2 {
+		t.Fatalf("Expected one repair request, got %d requests", len(fake.requests))
+	}
+
+	repair, err := buildMessages(fake.requests[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := repair[len(repair)-1].Content
+	if repair[len(repair)-2].Content != "- [ ] @{mañana} / Redes / Investigar OSPF" || !strings.Contains(last, `"mañana"`) {
+		t.Errorf("Repair request does not carry the previous answer and its problems: %q", last)
+	}
+}
+
+func TestExtractNoteTasksQuarantinesUnrepairableAnswer(t *testing.T) {
+	setupTestDB(t)
+	fake := &fakeExtractor{answer: "Claro, aquí están las tareas:\n- [ ] @{2026-10-24} / Redes / Investigar OSPF"}
+
+	tasks, err := extractNoteTasks(context.Background(), configSnapshot(), fake, ExtractionRequest{Content: "nota", SourcePath: "/notas/a.md", Now: time.Now()})
+	var invalid *invalidAnswerError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected an invalid answer error, got %v", err)
+	}
+	if len(fake.requests) != configSnapshot().RepairAttempts+1 {
+		t.Errorf("Expected %d requests, got %d", configSnapshot().RepairAttempts+1, len(fake.requests))
+	}
+	if len(tasks) != 1 || tasks[0].Description != "Investigar OSPF" {
+		t.Errorf("Valid tasks of the answer were not kept: %+v", tasks)
+	}
+
+	list, err := quarantinedResponses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("Expected 1 quarantined response, got %d", len(list))
+	}
+	q := list[0]
+	if q.SourcePath != "/notas/a.md" || q.Backend != "fake" || q.Response != fake.answer || q.Attempts != configSnapshot().RepairAttempts+1 || len(q.Problems) != 1 {
+		t.Errorf("Unexpected quarantined response: %+v", q)
+	}
+
+	if err := deleteQuarantinedResponse(q.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteQuarantinedResponse(q.ID); err == nil {
+		t.Error("Expected error deleting a missing entry")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+var (
+	// defaultBackendTimeout bounds each call to a backend without a timeout
+	// of its own.
+	defaultBackendTimeout = 2 * time.Minute
+
+	retryBaseDelay = time.Second
+	retryMaxDelay  = time.Minute
+	// maxRetryAfter is the longest Retry-After honored in place; longer
+	// waits are left to the retry queue.
+	maxRetryAfter = 5 * time.Minute
+)
+
+// retryableError is a backend failure that may succeed if repeated, such as
+// a refused connection, a 429 or a 5xx. retryAfter is the wait the server
+// asked for, if any.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// statusError classifies a non-200 response: 429 and 5xx are retryable and
+// honor Retry-After, anything else is returned as is.
+func statusError(resp *http.Response, err error) error {
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return &retryableError{err: err, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	}
+	return err
+}
+
+// parseRetryAfter reads a Retry-After header in either of its forms: a
+// number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// geminiError classifies an error from the Gemini client. Quota and server
+// errors are retryable, using the delay from the RetryInfo detail when the
+// API sends one.
+func geminiError(err error) error {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		// Errores de red: vale la pena reintentar.
+		return &retryableError{err: err}
+	}
+	if apiErr.Code != http.StatusTooManyRequests && apiErr.Code < 500 {
+		return err
+	}
+	retry := &retryableError{err: err}
+	for _, detail := range apiErr.Details {
+		if delay, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(delay); err == nil {
+				retry.retryAfter = d
+			}
+		}
+	}
+	return retry
+}
+
+func (c *Config) backendTimeout(name string) time.Duration {
+	if d := c.backend(name).Timeout; d > 0 {
+		return d
+	}
+	return defaultBackendTimeout
+}
+
+// backoff returns the wait before retry number attempt+1: exponential from
+// retryBaseDelay up to retryMaxDelay, with the upper half randomized so that
+// notes failing together do not retry together.
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << attempt
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// callBackend runs call with the backend's timeout from cfg, retrying
+// retryable failures with backoff. Timeouts of a single call are retried
+// too; the caller's ctx being done is not.
+func callBackend(ctx context.Context, cfg *Config, name string, call func(context.Context) (string, error)) (string, error) {
+	timeout := cfg.backendTimeout(name)
+	for attempt := 0; ; attempt++ {
+		release, err := acquireBackend(ctx, cfg, name)
+		if err != nil {
+			return "", err
+		}
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		answer, err := call(callCtx)
+		cancel()
+		release()
+		if err == nil {
+			return answer, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		var retry *retryableError
+		if !errors.As(err, &retry) && !errors.Is(err, context.DeadlineExceeded) {
+			return "", err
+		}
+		if attempt >= cfg.BackendRetries {
+			return "", err
+		}
+		delay := backoff(attempt)
+		if retry != nil && retry.retryAfter > delay {
+			if retry.retryAfter > maxRetryAfter {
+				return "", err
+			}
+			delay = retry.retryAfter
+		}
+
+		log.Printf("Error llamando a %s, reintento %d/%d en %v: %v", name, attempt+1, cfg.BackendRetries, delay.Round(time.Millisecond), err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// fastRetries shortens the backoff for the rest of the test.
+func fastRetries(t *testing.T) {
+	t.Helper()
+	base, max := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { retryBaseDelay, retryMaxDelay = base, max })
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"Sat, 17 Oct 2026 12:01:00 GMT", time.Minute},
+		{"Sat, 17 Oct 2026 11:00:00 GMT", 0},
+		{"pronto", 0},
+	}
+	for _, c := range cases {
+		if got := parseRetryAfter(c.value, now); got != c.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", c.value, got, c.want)
+		}
+	}
+}
+
+func TestBackoffStaysWithinBounds(t *testing.T) {
+	for attempt := 0; attempt < 20; attempt++ {
+		d := backoff(attempt)
+		if d < 0 || d > retryMaxDelay {
+			t.Errorf("backoff(%d) = %v out of bounds", attempt, d)
+		}
+	}
+}
+
+func TestCallBackendRetriesOllamaWhileLoading(t *testing.T) {
+	fastRetries(t)
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(OllamaResponse{Message: Message{Role: "assistant", Content: "None"}})
+	}))
+	defer ts.Close()
+
+	ext := &ollamaExtractor{url: ts.URL, model: "x", client: http.DefaultClient}
+	answer, err := callBackend(context.Background(), configSnapshot(), ext.Name(), func(ctx context.Context) (string, error) {
+		return ext.Extract(ctx, ExtractionRequest{Now: time.Now()})
+	})
+	if err != nil || answer != "None" || calls != 3 {
+		t.Errorf("Expected success on the third call, got %q, %v after %d calls", answer, err, calls)
+	}
+}
+
+func TestCallBackendDoesNotRetryClientErrors(t *testing.T) {
+	fastRetries(t)
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	ext := &ollamaExtractor{url: ts.URL, model: "x", client: http.DefaultClient}
+	_, err := callBackend(context.Background(), configSnapshot(), ext.Name(), func(ctx context.Context) (string, error) {
+		return ext.Extract(ctx, ExtractionRequest{Now: time.Now()})
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Expected a single failed call, got %v after %d calls", err, calls)
+	}
+}
+
+func TestCallBackendTimeout(t *testing.T) {
+	fastRetries(t)
+	cfg := *configSnapshot()
+	cfg.Ollama.Timeout = 20 * time.Millisecond
+	cfg.BackendRetries = 1
+
+	calls := 0
+	_, err := callBackend(context.Background(), &cfg, "ollama", func(ctx context.Context) (string, error) {
+		calls++
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || calls != 2 {
+		t.Errorf("Expected the timed out call to be retried once, got %v after %d calls", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	_, err = callBackend(ctx, configSnapshot(), "ollama", func(ctx context.Context) (string, error) {
+		calls++
+		return "", &retryableError{err: errors.New("caído")}
+	})
+	// Un escaneo cancelado ni siquiera espera un turno del backend.
+	if !errors.Is(err, context.Canceled) || calls != 0 {
+		t.Errorf("Expected a cancelled scan to stop retrying, got %v after %d calls", err, calls)
+	}
+}
+
+func TestGeminiError(t *testing.T) {
+	quota := genai.APIError{Code: 429, Details: []map[string]any{
+		{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "17s"},
+	}}
+	var retry *retryableError
+	if !errors.As(geminiError(quota), &retry) || retry.retryAfter != 17*time.Second {
+		t.Errorf("Quota error not retryable with its delay: %+v", retry)
+	}
+	if _, ok := geminiError(genai.APIError{Code: 400}).(*retryableError); ok {
+		t.Errorf("Bad request should not be retried")
+	}
+}
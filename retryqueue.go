@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// retryQueueInterval is how often the queue is checked for notes that
+	// are due.
+	retryQueueInterval = time.Minute
+	// retryQueueBaseDelay is the wait after the first failure; it doubles
+	// with every failure up to retryQueueMaxDelay.
+	retryQueueBaseDelay = time.Minute
+	retryQueueMaxDelay  = 6 * time.Hour
+	// maxQueuedAttempts is how many times a note is retried before it is
+	// dropped from the queue.
+	maxQueuedAttempts = 10
+)
+
+// queuedNote is a note whose extraction failed and will be tried again.
+type queuedNote struct {
+	Path          string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+}
+
+// queueRetry records a failed extraction of path and schedules the next
+// attempt. Callers must hold mutex.
+func queueRetry(path string, cause error) error {
+	var attempts int
+	err := db.QueryRow("SELECT attempts FROM retry_queue WHERE path = ?", path).Scan(&attempts)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error querying retry queue: %w", err)
+	}
+	attempts++
+
+	if attempts > maxQueuedAttempts {
+		log.Printf("Se descarta %s de la cola de reintentos tras %d intentos: %v", path, maxQueuedAttempts, cause)
+		return dequeueRetry(path)
+	}
+
+	delay := retryQueueBaseDelay << (attempts - 1)
+	if delay <= 0 || delay > retryQueueMaxDelay {
+		delay = retryQueueMaxDelay
+	}
+	_, err = db.Exec(`INSERT INTO retry_queue(path, attempts, last_error, next_attempt_at) VALUES(?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET attempts = excluded.attempts, last_error = excluded.last_error, next_attempt_at = excluded.next_attempt_at`,
+		path, attempts, cause.Error(), time.Now().Add(delay).Format(TimeFormat))
+	if err != nil {
+		return fmt.Errorf("error updating retry queue: %w", err)
+	}
+	return nil
+}
+
+// retryLater logs a failed extraction and queues the note.
+func retryLater(path string, cause error) {
+	log.Printf("Error procesando %s, se reintentará más tarde: %v", path, cause)
+	mutex.Lock()
+	err := queueRetry(path, cause)
+	mutex.Unlock()
+	if err != nil {
+		log.Printf("Error encolando %s para reintento: %v", path, err)
+	}
+}
+
+// dequeueRetry removes path from the queue. Callers must hold mutex.
+func dequeueRetry(path string) error {
+	if _, err := db.Exec("DELETE FROM retry_queue WHERE path = ?", path); err != nil {
+		return fmt.Errorf("error deleting from retry queue: %w", err)
+	}
+	return nil
+}
+
+// dueRetries returns the queued notes whose next attempt is at or before
+// now. Callers must hold mutex.
+func dueRetries(now time.Time) ([]queuedNote, error) {
+	rows, err := db.Query(`SELECT path, attempts, last_error, next_attempt_at FROM retry_queue
+		WHERE next_attempt_at <= ? ORDER BY next_attempt_at`, now.Format(TimeFormat))
+	if err != nil {
+		return nil, fmt.Errorf("error querying retry queue: %w", err)
+	}
+	defer rows.Close()
+
+	var due []queuedNote
+	for rows.Next() {
+		var q queuedNote
+		var next string
+		if err := rows.Scan(&q.Path, &q.Attempts, &q.LastError, &next); err != nil {
+			return nil, fmt.Errorf("error scanning retry queue: %w", err)
+		}
+		q.NextAttemptAt, _ = time.Parse(TimeFormat, next)
+		due = append(due, q)
+	}
+	return due, rows.Err()
+}
+
+// processRetryQueue tries every due note again. Notes that are processed, or
+// turn out to need no processing any more, leave the queue; the rest are
+// rescheduled by processFile.
+func processRetryQueue(ctx context.Context) {
+	mutex.RLock()
+	due, err := dueRetries(time.Now())
+	mutex.RUnlock()
+	if err != nil {
+		log.Printf("Error consultando la cola de reintentos: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for _, q := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		wg.Add(1)
+		notePool.Go(func() {
+			defer wg.Done()
+			log.Printf("Reintentando %s (intento %d)", q.Path, q.Attempts+1)
+			if err := processNote(ctx, q.Path, time.Time{}); err != nil {
+				retryLater(q.Path, err)
+				return
+			}
+			mutex.Lock()
+			err := dequeueRetry(q.Path)
+			mutex.Unlock()
+			if err != nil {
+				log.Printf("Error actualizando la cola de reintentos: %v", err)
+			}
+		})
+	}
+}
+
+// runRetryQueue calls processRetryQueue every retryQueueInterval until ctx
+// is done.
+func runRetryQueue(ctx context.Context) {
+	ticker := time.NewTicker(retryQueueInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			processRetryQueue(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFailedNoteIsQueuedAndRetried(t *testing.T) {
+	setupTestDB(t)
+	fake := &fakeExtractor{err: errors.New("backend caído")}
+	useFakeExtractor(t, fake)
+
+	note := filepath.Join(t.TempDir(), "Redes", time.Now().Format("2006-01-02")+" Redes.md")
+	os.MkdirAll(filepath.Dir(note), 0755)
+	os.WriteFile(note, []byte("Investigar OSPF"), 0644)
+
+	processFile(context.Background(), note)
+
+	// Todavía no le toca.
+	due, err := dueRetries(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 0 {
+		t.Fatalf("Note should not be due yet: %+v", due)
+	}
+	due, _ = dueRetries(time.Now().Add(retryQueueBaseDelay))
+	if len(due) != 1 || due[0].Path != note || due[0].Attempts != 1 || due[0].LastError == "" {
+		t.Fatalf("Failed note was not queued: %+v", due)
+	}
+
+	// Un segundo fallo duplica la espera.
+	mutex.Lock()
+	queueRetry(note, errors.New("otra vez"))
+	mutex.Unlock()
+	due, _ = dueRetries(time.Now().Add(retryQueueBaseDelay))
+	if len(due) != 0 {
+		t.Errorf("Second failure should wait longer: %+v", due)
+	}
+
+	// Ya vencida, el reintento procesa la nota y la saca de la cola.
+	mutex.Lock()
+	db.Exec("UPDATE retry_queue SET next_attempt_at = ?", time.Now().Add(-time.Minute).Format(TimeFormat))
+	mutex.Unlock()
+	fake.err = nil
+	fake.answer = "- [ ] @{2026-10-24} / Redes / Investigar OSPF"
+	processRetryQueue(context.Background())
+
+	tasks, _ := tasksFromSource(note)
+	if len(tasks) != 1 {
+		t.Errorf("Queued note was not processed: %+v", tasks)
+	}
+	due, _ = dueRetries(time.Now().Add(retryQueueMaxDelay))
+	if len(due) != 0 {
+		t.Errorf("Processed note was not removed from the queue: %+v", due)
+	}
+}
+
+func TestQueueRetryDropsAfterMaxAttempts(t *testing.T) {
+	setupTestDB(t)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for i := 0; i <= maxQueuedAttempts; i++ {
+		if err := queueRetry("/notas/a.md", errors.New("caído")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due, _ := dueRetries(time.Now().Add(retryQueueMaxDelay))
+	if len(due) != 0 {
+		t.Errorf("Note should have been dropped after %d attempts: %+v", maxQueuedAttempts, due)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerExtractor("rules", func(*Config) (TaskExtractor, error) {
+		return &rulesExtractor{}, nil
+	})
+}
+
+// rulesExtractor reads the tasks a note already spells out, without an LLM:
+// open markdown checkboxes, "TODO:" lines and the Obsidian Tasks due date
+// "📅 YYYY-MM-DD". It needs no network, so it also works as an offline mode
+// or as the last backend of a fallback chain.
+type rulesExtractor struct{}
+
+func (r *rulesExtractor) Name() string  { return "rules" }
+func (r *rulesExtractor) Model() string { return "" }
+func (r *rulesExtractor) promptless()   {}
+
+var (
+	checkboxRe = regexp.MustCompile(`^\s*[-*+]\s+\[ \]\s+(.+)$`)
+	todoRe     = regexp.MustCompile(`^\s*(?:[-*+]\s+)?TODO:\s*(.+)$`)
+	dueEmojiRe = regexp.MustCompile(`📅\s*(\d{4}-\d{2}-\d{2})`)
+	// Other Obsidian Tasks fields: scheduled, start, created and done dates,
+	// recurrence and priority. They are dropped from the description.
+	taskFieldsRe = regexp.MustCompile(`(?:[⏳🛫➕✅]\s*\d{4}-\d{2}-\d{2}|🔁[^📅⏳🛫➕✅⏫🔼🔽🔺⏬]*|[⏫🔼🔽🔺⏬])`)
+)
+
+// explicitTask returns the task written on line, if any.
+func explicitTask(line string, req ExtractionRequest) (ExtractedTask, bool) {
+	m := checkboxRe.FindStringSubmatch(line)
+	if m == nil {
+		m = todoRe.FindStringSubmatch(line)
+	}
+	if m == nil {
+		return ExtractedTask{}, false
+	}
+
+	text := strings.ReplaceAll(m[1], "\uFE0F", "")
+	// Como en el prompt: sin fecha de entrega, vence al día siguiente.
+	dueDate := req.Now.AddDate(0, 0, 1).Format(DateFormat)
+	if d := dueEmojiRe.FindStringSubmatch(text); d != nil {
+		if _, err := time.Parse(DateFormat, d[1]); err == nil {
+			dueDate = d[1]
+		}
+	}
+	text = dueEmojiRe.ReplaceAllString(text, "")
+	text = strings.Join(strings.Fields(taskFieldsRe.ReplaceAllString(text, "")), " ")
+	if text == "" {
+		return ExtractedTask{}, false
+	}
+
+	subject := req.Subject
+	if subject == "" {
+		subject = "General"
+	}
+	return ExtractedTask{DueDate: dueDate, Subject: subject, Description: text, Confidence: 1}, true
+}
+
+// explicitTasks scans content outside code fences. It also returns the
+// content with the task lines removed, for the LLM to read.
+func explicitTasks(content string, req ExtractionRequest) ([]ExtractedTask, string) {
+	var tasks []ExtractedTask
+	var rest strings.Builder
+	inFence := false
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if !inFence {
+			if t, ok := explicitTask(strings.TrimRight(line, "\r\n"), req); ok {
+				tasks = append(tasks, t)
+				continue
+			}
+		}
+		rest.WriteString(line)
+	}
+	return tasks, rest.String()
+}
+
+// hasProse reports whether content has anything but headings, blank lines
+// and frontmatter delimiters.
+func hasProse(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && trimmed != "---" && !strings.HasPrefix(trimmed, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rulesExtractor) Extract(ctx context.Context, req ExtractionRequest) (string, error) {
+	tasks, _ := explicitTasks(req.Content, req)
+	if len(tasks) == 0 {
+		return "None", nil
+	}
+	var lines []string
+	for _, t := range tasks {
+		lines = append(lines, fmt.Sprintf("- [ ] @{%s} / %s / %s", t.DueDate, t.Subject, t.Description))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// ExtractJSON keeps the confidence of explicit tasks, which Extract's
+// markdown cannot carry.
+func (r *rulesExtractor) ExtractJSON(ctx context.Context, req ExtractionRequest) (string, error) {
+	tasks, _ := explicitTasks(req.Content, req)
+	if tasks == nil {
+		tasks = []ExtractedTask{}
+	}
+	answer, err := json.Marshal(map[string]any{"tasks": tasks})
+	if err != nil {
+		return "", err
+	}
+	return string(answer), nil
+}
+
+// sectionExtraction is the tasks one backend produced for a section.
+type sectionExtraction struct {
+	ext   TaskExtractor
+	tasks []ExtractedTask
+}
+
+// extractSection extracts the tasks of one section with chain. With
+// RULES_PREPASS, explicit tasks are read by the rules extractor first and
+// only the remaining prose, if any, is sent to chain.
+func extractSection(ctx context.Context, cfg *Config, chain []TaskExtractor, req ExtractionRequest) ([]sectionExtraction, error) {
+	if !cfg.RulesPrepass {
+		tasks, ext, err := extractWithFallback(ctx, cfg, chain, req)
+		if err != nil {
+			return nil, err
+		}
+		return []sectionExtraction{{ext, tasks}}, nil
+	}
+
+	explicit, prose := explicitTasks(req.Content, req)
+	result := []sectionExtraction{{&rulesExtractor{}, explicit}}
+	if !hasProse(prose) {
+		return result, nil
+	}
+
+	req.Content = prose
+	tasks, ext, err := extractWithFallback(ctx, cfg, chain, req)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, sectionExtraction{ext, tasks}), nil
+}
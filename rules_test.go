@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplicitTasks(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	req := ExtractionRequest{Subject: "Redes", Now: now}
+	content := strings.Join([]string{
+		"# Clase de Redes",
+		"El profesor mencionó que debemos terminar la configuración del router.",
+		"- [ ] Configurar VLAN 10",
+		"  * [ ] Configurar VLAN 20 📅 2026-10-20 ⏫",
+		"- [x] Instalar Packet Tracer",
+		"TODO: Investigar OSPF 🔁 every week 📅 2026-10-24",
+		"```",
+		"- [ ] no es una tarea",
+		"```",
+		"- [ ] ",
+	}, "\n")
+
+	tasks, rest := explicitTasks(content, req)
+	want := []ExtractedTask{
+		{DueDate: "2026-10-18", Subject: "Redes", Description: "Configurar VLAN 10", Confidence: 1},
+		{DueDate: "2026-10-20", Subject: "Redes", Description: "Configurar VLAN 20", Confidence: 1},
+		{DueDate: "2026-10-24", Subject: "Redes", Description: "Investigar OSPF", Confidence: 1},
+	}
+	if len(tasks) != len(want) {
+		t.Fatalf("Expected %d tasks, got %+v", len(want), tasks)
+	}
+	for i := range want {
+		if tasks[i] != want[i] {
+			t.Errorf("task %d = %+v, want %+v", i, tasks[i], want[i])
+		}
+	}
+	if strings.Contains(rest, "VLAN") || !strings.Contains(rest, "router") || !strings.Contains(rest, "no es una tarea") {
+		t.Errorf("Unexpected remaining content:\n%s", rest)
+	}
+	if hasProse("# Clase\n\n---\n") || !hasProse(rest) {
+		t.Errorf("hasProse misclassified content")
+	}
+}
+
+func TestRulesExtractorStandalone(t *testing.T) {
+	ext, err := extractorFactories["rules"](configSnapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := extractNoteTasks(context.Background(), configSnapshot(), ext, ExtractionRequest{Content: "- [ ] Configurar VLAN 10\nTexto suelto", Subject: "Redes", Now: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Description != "Configurar VLAN 10" || tasks[0].Confidence != 1 {
+		t.Errorf("Unexpected tasks: %+v", tasks)
+	}
+
+	answer, _ := ext.Extract(context.Background(), ExtractionRequest{Content: "Sin tareas", Now: time.Now()})
+	if answer != "None" {
+		t.Errorf("Expected None, got %q", answer)
+	}
+}
+
+func TestRulesPrepassSendsOnlyProseToLLM(t *testing.T) {
+	setupTestDB(t)
+
+	var prompts []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+		json.NewEncoder(w).Encode(ollamaAnswer(ExtractedTask{DueDate: "2026-10-24", Subject: "Redes", Description: "Investigar sobre OSPF", Confidence: 0.8}))
+	}))
+	defer ts.Close()
+	useConfig(t, func(cfg *Config) {
+		cfg.Ollama.URL = ts.URL
+		cfg.RulesPrepass = true
+	})
+
+	note := filepath.Join(t.TempDir(), "Redes", time.Now().Format("2006-01-02")+" Redes.md")
+	os.MkdirAll(filepath.Dir(note), 0755)
+	os.WriteFile(note, []byte("# Clase\n- [ ] Configurar VLAN 10\n# Checklist\n- [ ] Configurar VLAN 20\n# Tarea\nInvestigar sobre OSPF para el viernes.\n"), 0644)
+	processFile(context.Background(), note)
+
+	if len(prompts) != 1 || strings.Contains(prompts[0], "VLAN") {
+		t.Fatalf("Only the prose section should reach the LLM, got %d prompts: %q", len(prompts), prompts)
+	}
+	tasks, err := tasksFromSource(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	by := map[string]string{}
+	for _, task := range tasks {
+		by[task.Description] = task.ExtractedBy
+	}
+	if by["Configurar VLAN 10"] != "rules" || by["Configurar VLAN 20"] != "rules" || by["Investigar sobre OSPF"] != "ollama" {
+		t.Errorf("Unexpected tasks: %+v", by)
+	}
+}
@@ -1,328 +1,229 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
-	"google.golang.org/genai"
 )
 
-var (
-	defaultScanDir string
-	ollamaModel    string
-	geminiModel    string
-	ollamaURL      string
-	useGemini      bool
-)
-
-const (
-	daysToReview   = 7
-	metadataHeader = "---\nprocesado_por_ia: true\n---\n\n"
-)
+const metadataHeader = "---\nprocesado_por_ia: true\n---\n\n"
 
 func init() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No se pudo cargar el archivo .env, usando variables de entorno del sistema")
 	}
 
-	defaultScanDir = os.Getenv("DIRECTORIO_NOTAS")
-	ollamaModel = os.Getenv("OLLAMA_MODEL")
-	ollamaURL = os.Getenv("OLLAMA_URL")
-	geminiModel = os.Getenv("GEMINI_MODEL")
-	
-	// Si la variable USE_GEMINI es "true", activamos Gemini
-	useGemini = os.Getenv("USE_GEMINI") == "true"
-
-	if defaultScanDir == "" {
-		log.Println("ADVERTENCIA: DIRECTORIO_NOTAS no definido")
-	}
-	if ollamaURL == "" {
-		// Valor por defecto seguro si no se define, para evitar crashes
-		ollamaURL = "http://localhost:11434/api/chat"
-	}
-}
-
-type OllamaRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
-}
-
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	// Sin archivo de configuración todavía: main lo carga con -config.
+	cfg := builtinConfig
+	applyEnv(&cfg)
+	applyConfig(cfg)
 }
 
-type OllamaResponse struct {
-	Message Message `json:"message"`
+func scanAndProcessDirectory(ctx context.Context, scanDir string) {
+	scanDirectory(ctx, scanDir, func(path string) { processFile(ctx, path) })
 }
 
-func scanAndProcessDirectory(scanDir string) {
+// scanDirectory calls process on notePool for every file in scanDir that is
+// not ignored, and waits for all of them.
+func scanDirectory(ctx context.Context, scanDir string, process func(path string)) {
 	if _, err := os.Stat(scanDir); os.IsNotExist(err) {
 		log.Printf("Directorio de escaneo no encontrado: %s", scanDir)
 		return
 	}
 
-	log.Println("Iniciando escaneo de directorio...")
+	log.Printf("Iniciando escaneo de %s...", scanDir)
+	cfg := configSnapshot()
+	root := cfg.rootFor(scanDir)
+	var wg sync.WaitGroup
 	err := filepath.WalkDir(scanDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if root.ignores(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if !d.IsDir() {
-			processFile(path)
+			wg.Add(1)
+			notePool.Go(func() {
+				defer wg.Done()
+				process(path)
+			})
 		}
 		return nil
 	})
+	wg.Wait()
 	if err != nil {
 		log.Printf("Error al escanear directorio: %v", err)
 	}
 	log.Println("Escaneo finalizado.")
 }
 
-func processFile(path string) {
+// processFile extracts the tasks of a note. Notes whose extraction fails are
+// added to the retry queue instead of waiting for the next full scan.
+func processFile(ctx context.Context, path string) {
+	if err := processNote(ctx, path, time.Time{}); err != nil {
+		retryLater(path, err)
+	}
+}
+
+// processNote does the work of processFile. It returns nil both when the
+// note was processed and when there was nothing to do. The whole note is
+// processed with the configuration in use when it starts. A non-zero since
+// replaces the lookback window of the note's root.
+func processNote(ctx context.Context, path string, since time.Time) error {
 	filename := filepath.Base(path)
 	if !strings.HasSuffix(filename, ".md") {
-		return
+		return nil
 	}
 
-	dateRegex := regexp.MustCompile(`(\d{4}-\d{2}-\d{2})`)
-	match := dateRegex.FindStringSubmatch(filename)
-	if match == nil {
-		return
-	}
+	noteLocks.Lock(path)
+	defer noteLocks.Unlock(path)
+	cfg := configSnapshot()
 
-	fileDate, err := time.Parse("2006-01-02", match[1])
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// La nota se borró antes de procesarla.
+		return nil
+	}
 	if err != nil {
-		return
+		return fmt.Errorf("error leyendo archivo: %w", err)
 	}
 
-	if time.Since(fileDate).Hours() > 24*daysToReview {
-		return
+	root := cfg.rootFor(path)
+	noteDate, ok, err := root.noteDate(path, info)
+	if err != nil {
+		return err
+	}
+	if !ok || !root.inWindow(noteDate, since) {
+		return nil
 	}
 
 	contentBytes, err := os.ReadFile(path)
 	if err != nil {
-		log.Printf("Error leyendo archivo %s: %v", path, err)
-		return
+		return fmt.Errorf("error leyendo archivo: %w", err)
 	}
 	content := string(contentBytes)
 
-	if strings.Contains(content, "procesado_por_ia: true") {
-		return
+	mutex.Lock()
+	process, err := shouldProcessNote(cfg, path, info, contentBytes)
+	mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("error consultando el estado de la nota: %w", err)
 	}
-
-	log.Printf("Procesando archivo nuevo: %s", filename)
-	subject := filepath.Base(filepath.Dir(path))
-	
-	// Delegar la extracción a la función agnóstica
-	tasks := extractTasks(content, filename, subject)
-
-	if tasks != "None" && tasks != "" {
-		lines := strings.Split(tasks, "\n")
-		for _, lineStr := range lines {
-			trimmed := strings.TrimSpace(lineStr)
-			if strings.HasPrefix(trimmed, "- [ ]") {
-				// Parse the task line to extract content, checked status, and completed_at
-				// This logic is similar to what was in loadFullFileStructure and appendTasksToFileModel
-				content := strings.TrimPrefix(trimmed, "- [ ]")
-				content = strings.TrimSpace(content)
-
-				// Extract completed_at if present in the format @{YYYY-MM-DD HH:MM:SS}
-				var completedAt *time.Time
-				if dateIndex := strings.LastIndex(content, " @{"); dateIndex != -1 && strings.HasSuffix(content, "}") {
-					dateStr := content[dateIndex+3 : len(content)-1]
-					if t, err := time.Parse(TimeFormat, dateStr); err == nil {
-						completedAt = &t
-					}
-					content = strings.TrimSpace(content[:dateIndex])
-				}
-
-				p := Pendiente{
-					Text:        content,
-					Checked:     false, // Newly extracted tasks are unchecked by default
-					CompletedAt: completedAt,
-				}
-
-				// Use the mutex defined in server.go to protect DB access
-				mutex.Lock()
-				err = insertTaskIntoDB(p)
-				mutex.Unlock()
-
-				if err != nil {
-					log.Printf("Error al insertar tarea '%s' en la DB: %v", p.Text, err)
-				} else {
-					log.Printf("Tarea insertada: %s", p.Text)
-				}
-			}
-		}
-		markFileAsProcessed(path, content)
-	} else if tasks == "None" {
-		log.Printf("No se encontraron tareas en %s. Marcando como procesado.", filename)
-		markFileAsProcessed(path, content)
+	if !process {
+		return nil
 	}
-}
 
-// extractTasks decide qué backend usar basado en la variable global useGemini
-func extractTasks(content, filename, subject string) string {
-	if useGemini {
-		return extractTasksWithGemini(content, filename, subject)
-	}
-	return extractTasksWithOllama(content, filename, subject)
-}
+	log.Printf("Procesando archivo: %s", filename)
+	subject := root.subject(filepath.Dir(path))
 
-func extractTasksWithGemini(content, filename, subject string) string {
-	ctx := context.Background()
-	// El cliente toma la API KEY de la variable de entorno GEMINI_API_KEY por defecto si config es nil
-	client, err := genai.NewClient(ctx, nil)
+	chain, err := extractorsFor(cfg, root.Extractor)
 	if err != nil {
-		log.Printf("Error creando cliente Gemini: %v", err)
-		return ""
+		return fmt.Errorf("error seleccionando el extractor: %w", err)
 	}
-
-	systemPrompt := `Dado el siguiente archivo markdown, extrae una lista de tareas o pendientes que se pueden identificar en el contenido. Si no hay tareas, responde vacio.
-si hay tareas, responde con una lista en formato markdown, cada tarea debe empezar con un guión.
-No agregues nada más, solo la lista de tareas.
-No agregues explicaciones ni introducciones, solo la lista de tareas.
-La lista debe ser como la siguiente:
-    - [ ] @{ *fecha de entrega en formato YYYY-MM-DD* } / *Materia* / *Descripcion*
-Asegúrate de que las fechas de entrega estén en el formato @{YYYY-MM-DD} y si no existe una fecha de entrega, asume que la fecha de entrega es el dia siguiente
-Si no puedes encontrar una materia, usa "General" como materia.
-Divide la fecha de entrega, la materia y la descripcion con una barra inclinada (/).
-
-Si no hay tareas, responde "None".`
-
-	// Construimos el contexto completo en texto plano para Gemini
-	fullPrompt := fmt.Sprintf("%s\n\nEjemplo Usuario:\n%s\n\nEjemplo Asistente:\n%s\n\nEjemplo Usuario 2:\n%s\n\nEjemplo Asistente 2:\n%s\n\nTarea Actual:\nEl nombre de la materia es %s,\nFecha actual: %s\nDia de la semana actual: %s\nNombre del archivo: %s\n```markdown\n%s\n```",
-		systemPrompt,
-		generateEmptyTasksExample(),
-		"None",
-		generateTasksExample(),
-		"- [ ] @{2025-08-31} / Internet of Things / Construir una cerradura combinacional con 8 entradas y 5 digitos, verificar la contraseña al presionar enter, preparar documentación en PDF (incluyendo circuito, diagrama de bloques, diagrama eléctrico, código fuente y circuito funcionando)",
-		subject,
-		time.Now().Format("2006-01-02"),
-		time.Now().Weekday().String(),
-		filename,
-		content,
-	)
-
-	result, err := client.Models.GenerateContent(
-		ctx,
-		geminiModel,
-		genai.Text(fullPrompt),
-		nil,
-	)
+	prompts, err := loadPromptSet(cfg.PromptsDir, subject)
 	if err != nil {
-		log.Printf("Error llamando a Gemini API: %v", err)
-		return ""
+		return err
 	}
 
-	return result.Text()
-}
-
-func extractTasksWithOllama(content, filename, subject string) string {
-	prompt := fmt.Sprintf(`
-        El nombre de la materia es %s,
-
-
-        Fecha actual: %s
-        Dia de la semana actual: %s
-        Nombre del archivo: %s
-	%s
-	%s
-	%s
-	`,
-		subject,
-		time.Now().Format("2006-01-02"),
-		time.Now().Weekday().String(),
-		filename,
-		"```markdown",
-		content,
-		"```",
-	)
+	mutex.RLock()
+	known, err := noteSectionHashes(path)
+	mutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("error consultando secciones: %w", err)
+	}
 
-	reqBody := OllamaRequest{
-		Model:  ollamaModel,
-		Stream: false,
-		Messages: []Message{
-			{Role: "system", Content: `Dado el siguiente archivo markdown, extrae una lista de tareas o pendientes que se pueden identificar en el contenido. Si no hay tareas, responde vacio.
-si hay tareas, responde con una lista en formato markdown, cada tarea debe empezar con un guión.
-No agregues nada más, solo la lista de tareas.
-No agregues explicaciones ni introducciones, solo la lista de tareas.
-La lista debe ser como la siguiente:
-    - [ ] @{ *fecha de entrega en formato YYYY-MM-DD* } / *Materia* / *Descripcion*
-Asegúrate de que las fechas de entrega estén en el formato @{YYYY-MM-DD} y si no existe una fecha de entrega, asume que la fecha de entrega es el dia siguiente
-Si no puedes encontrar una materia, usa "General" como materia.
-Divide la fecha de entrega, la materia y la descripcion con una barra inclinada (/).
+	// Solo las secciones nuevas o modificadas se envían al LLM.
+	sections := splitNoteSections(content)
+	var extracted []Pendiente
+	var current []string
+	for _, sec := range sections {
+		current = append(current, sec.Hash)
+		if known[sec.Hash] {
+			continue
+		}
 
-Si no hay tareas, responde "None".`},
-			{Role: "user", Content: generateEmptyTasksExample()},
-			{Role: "assistant", Content: "None"},
-			{Role: "user", Content: generateTasksExample()},
-			{Role: "assistant", Content: "- [ ] @{2025-08-31} / Internet of Things / Construir una cerradura combinacional con 8 entradas y 5 digitos, verificar la contraseña al presionar enter, preparar documentación en PDF (incluyendo circuito, diagrama de bloques, diagrama eléctrico, código fuente y circuito funcionando)"},
-			{Role: "user", Content: prompt},
-		},
+		// Las secciones largas se dividen para no exceder el contexto del modelo.
+		chunks := chunkSection(sec.Text, cfg.MaxChunkTokens)
+		if len(chunks) > 1 {
+			log.Printf("Sección de %s dividida en %d fragmentos", filename, len(chunks))
+		}
+		now := time.Now()
+		for _, chunk := range chunks {
+			results, err := extractSection(ctx, cfg, chain, ExtractionRequest{
+				Content:    chunk,
+				Filename:   filename,
+				Subject:    subject,
+				Now:        now,
+				SourcePath: path,
+				Prompts:    prompts,
+			})
+			if err != nil {
+				return fmt.Errorf("no se pudo extraer tareas: %w", err)
+			}
+			for _, r := range results {
+				for _, t := range r.tasks {
+					p := t.toPendiente()
+					p.SourcePath = path
+					p.SourceSection = sec.Hash
+					p.SourceSubject = subject
+					p.NoteDate = noteDate.Format(DateFormat)
+					p.ExtractedBy, p.ExtractionModel = r.ext.Name(), r.ext.Model()
+					if usesPrompt(r.ext) {
+						p.PromptVersion = prompts.Version
+					}
+					p.SourceSnippet = snippet(chunk)
+					extracted = append(extracted, p)
+				}
+			}
+		}
 	}
+	extracted = mergeExtractedTasks(extracted)
 
-	jsonData, _ := json.Marshal(reqBody)
-	resp, err := http.Post(ollamaURL, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		log.Printf("Error conectando con Ollama: %v", err)
-		return ""
+	if len(extracted) == 0 {
+		log.Printf("No se encontraron tareas nuevas en %s. Marcando como procesado.", filename)
 	}
-	defer resp.Body.Close()
 
-	var ollamaResp OllamaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
-		log.Printf("Error decodificando respuesta de Ollama: %v", err)
-		return ""
+	// Use the mutex defined in server.go to protect DB access
+	mutex.Lock()
+	err = reconcileNoteTasks(path, extracted, current)
+	mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("error al guardar las tareas: %w", err)
 	}
-
-	return ollamaResp.Message.Content
+	markFileAsProcessed(cfg, path, info, contentBytes)
+	return nil
 }
 
-
-
-func markFileAsProcessed(path, content string) {
-	newContent := metadataHeader + content
-	err := os.WriteFile(path, []byte(newContent), 0644)
+// markFileAsProcessed records the note in processed_files, or writes the
+// frontmatter marker into it when FRONTMATTER_MARKER is enabled.
+func markFileAsProcessed(cfg *Config, path string, info os.FileInfo, content []byte) {
+	var err error
+	if cfg.FrontmatterMarker {
+		err = os.WriteFile(path, []byte(addFrontmatterMarker(string(content))), 0644)
+	} else {
+		mutex.Lock()
+		err = recordProcessedNote(path, info, content)
+		mutex.Unlock()
+	}
 	if err != nil {
 		log.Printf("Error marcando archivo como procesado %s: %v", path, err)
 	} else {
 		log.Printf("Archivo marcado como procesado: %s", filepath.Base(path))
 	}
 }
-
-func generateEmptyTasksExample() string {
-	return `
-    El nombre de la materia es Sistemas de Informacion,
-
-
-    Fecha actual: 2023-10-10
-    Dia de la semana actual: Lunes
-    Nombre del archivo: "Sistemas de Informacion 2023-10-09.md"
-    ... (contenido irrelevante) ...
-    `
-}
-
-func generateTasksExample() string {
-	return `
-    El nombre de la materia es, Internet of Things,
-
-
-    Fecha actual: 2023-08-28
-    Dia de la semana actual: Jueves
-    Nombre del archivo: "Sistemas de Informacion 2023-08-27.md"
-    
-    Construir una cerradura combinacional...
-    `
-}
\ No newline at end of file
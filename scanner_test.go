@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -11,60 +12,91 @@ import (
 	"time"
 )
 
-func TestExtractTasksWithOllama(t *testing.T) {
-	mockResponse := OllamaResponse{
-		Message: Message{
-			Role:    "assistant",
-			Content: "- [ ] @{2026-02-20} / TestSubject / Test Task Description",
-		},
+// ollamaAnswer builds a structured Ollama response holding tasks.
+func ollamaAnswer(tasks ...ExtractedTask) OllamaResponse {
+	if tasks == nil {
+		tasks = []ExtractedTask{}
 	}
+	content, _ := json.Marshal(map[string]any{"tasks": tasks})
+	return OllamaResponse{Message: Message{Role: "assistant", Content: string(content)}}
+}
+
+func TestExtractTasksWithOllama(t *testing.T) {
+	setupTestDB(t)
+	mockResponse := ollamaAnswer(ExtractedTask{DueDate: "2026-02-20", Subject: "TestSubject", Description: "Test Task Description", Confidence: 0.9})
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			t.Errorf("Expected POST request, got %s", r.Method)
 		}
+		var req OllamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Format == nil {
+			t.Errorf("Expected a JSON schema in the format field")
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(mockResponse)
 	}))
 	defer ts.Close()
 
-	originalURL := ollamaURL
-	ollamaURL = ts.URL
-	defer func() { ollamaURL = originalURL }()
+	useConfig(t, func(cfg *Config) { cfg.Ollama.URL = ts.URL })
 
 	content := "Dummy content"
 	filename := "2026-01-13 TestFile.md"
 	subject := "TestSubject"
 
-	tasks := extractTasksWithOllama(content, filename, subject)
+	cfg := configSnapshot()
+	chain, err := extractorsFor(cfg, cfg.Extractor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, _, err := extractWithFallback(context.Background(), cfg, chain, ExtractionRequest{
+		Content:  content,
+		Filename: filename,
+		Subject:  subject,
+		Now:      time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if !strings.Contains(tasks, "Test Task Description") {
-		t.Errorf("Expected task description not found in response: %s", tasks)
+	if len(tasks) != 1 || tasks[0].Description != "Test Task Description" || tasks[0].Confidence != 0.9 {
+		t.Errorf("Unexpected tasks: %+v", tasks)
 	}
 }
 
-func TestAppendTasksToFileModel(t *testing.T) {
+// setupTestDB points the global db at a fresh SQLite file for the duration
+// of the test.
+func setupTestDB(t *testing.T) {
+	t.Helper()
 	mutex.Lock()
-	fileModel = []FileLine{}
-	markdownPath = "test_pendientes.md"
-	os.WriteFile(markdownPath, []byte(""), 0644)
-	mutex.Unlock()
+	defer mutex.Unlock()
 
-	defer os.Remove(markdownPath)
-
-	tasksText := "- [ ] @{2026-10-10} / GoTest / Task 1\n- [ ] Task 2 without date"
-
-	appendTasksToFileModel(tasksText)
-
-	mutex.RLock()
-	defer mutex.RUnlock()
+	if err := initDB(filepath.Join(t.TempDir(), "tasks.db")); err != nil {
+		t.Fatalf("initDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+}
 
-	if len(fileModel) != 2 {
-		t.Errorf("Expected 2 tasks in model, got %d", len(fileModel))
+func TestParseTaskText(t *testing.T) {
+	cases := []struct {
+		text        string
+		dueDate     string
+		subject     string
+		description string
+	}{
+		{"@{2026-10-10} / GoTest / Task 1", "2026-10-10", "GoTest", "Task 1"},
+		{"@{ 2026-10-10 } / Redes / Configurar VLAN 10 / VLAN 20", "2026-10-10", "Redes", "Configurar VLAN 10 / VLAN 20"},
+		{"@{mañana} / Redes / Investigar OSPF", "", "Redes", "Investigar OSPF"},
+		{"Task 2 without date", "", "", "Task 2 without date"},
 	}
 
-	if fileModel[0].Content != "@{2026-10-10} / GoTest / Task 1" {
-		t.Errorf("Unexpected content for task 1: %s", fileModel[0].Content)
+	for _, c := range cases {
+		dueDate, subject, description := parseTaskText(c.text)
+		if dueDate != c.dueDate || subject != c.subject || description != c.description {
+			t.Errorf("parseTaskText(%q) = (%q, %q, %q), want (%q, %q, %q)",
+				c.text, dueDate, subject, description, c.dueDate, c.subject, c.description)
+		}
 	}
 }
 
@@ -75,20 +107,13 @@ func TestProcessFileIntegration(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	mockResponse := OllamaResponse{
-		Message: Message{
-			Role:    "assistant",
-			Content: "- [ ] @{2026-05-05} / Integration / Task from File",
-		},
-	}
+	mockResponse := ollamaAnswer(ExtractedTask{DueDate: "2026-05-05", Subject: "Integration", Description: "Task from File", Confidence: 0.8})
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(mockResponse)
 	}))
 	defer ts.Close()
 
-	originalURL := ollamaURL
-	ollamaURL = ts.URL
-	defer func() { ollamaURL = originalURL }()
+	useConfig(t, func(cfg *Config) { cfg.Ollama.URL = ts.URL })
 
 	subjectDir := filepath.Join(tmpDir, "IntegrationSubject")
 	os.Mkdir(subjectDir, 0755)
@@ -101,31 +126,77 @@ func TestProcessFileIntegration(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	mutex.Lock()
-	fileModel = []FileLine{}
-	markdownPath = filepath.Join(tmpDir, "pendientes.md")
-	os.WriteFile(markdownPath, []byte(""), 0644)
-	mutex.Unlock()
+	setupTestDB(t)
 
-	scanAndProcessDirectory(tmpDir)
+	scanAndProcessDirectory(context.Background(), tmpDir)
 
 	processedContentBytes, _ := os.ReadFile(filePath)
-	processedContent := string(processedContentBytes)
-	if !strings.Contains(processedContent, "procesado_por_ia: true") {
-		t.Errorf("File was not marked as processed. Content:\n%s", processedContent)
+	if string(processedContentBytes) != initialContent {
+		t.Errorf("Note was modified. Content:\n%s", processedContentBytes)
+	}
+	if _, ok, err := getProcessedFile(filePath); err != nil || !ok {
+		t.Errorf("File was not recorded in processed_files (err=%v)", err)
 	}
 
-	mutex.RLock()
-	found := false
-	for _, line := range fileModel {
-		if strings.Contains(line.Content, "Task from File") {
-			found = true
-			break
-		}
+	tasks, err := getTasksFromDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("Expected 1 task in DB, got %d", len(tasks))
+	}
+	task := tasks[0]
+	if task.DueDate != "2026-05-05" || task.Subject != "Integration" || task.Description != "Task from File" {
+		t.Errorf("Task was not stored with structured fields: %+v", task)
+	}
+	if task.SourcePath != filePath || task.SourceSubject != "IntegrationSubject" || task.NoteDate != time.Now().Format("2006-01-02") {
+		t.Errorf("Task was not stored with its source: %+v", task)
+	}
+	if task.Confidence != 0.8 {
+		t.Errorf("Task confidence was not stored: %+v", task)
+	}
+	if task.ExtractedBy != "ollama" || !strings.Contains(task.SourceSnippet, "Some notes here.") {
+		t.Errorf("Task was not stored with extraction provenance: %+v", task)
 	}
-	mutex.RUnlock()
+}
+
+func TestProcessFileSkipsUnchangedAndReprocessesEdited(t *testing.T) {
+	setupTestDB(t)
 
-	if !found {
-		t.Errorf("Task was not added to fileModel")
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(ollamaAnswer())
+	}))
+	defer ts.Close()
+
+	useConfig(t, func(cfg *Config) { cfg.Ollama.URL = ts.URL })
+
+	filePath := filepath.Join(t.TempDir(), time.Now().Format("2006-01-02")+" Notes.md")
+	os.WriteFile(filePath, []byte("# Notes"), 0644)
+
+	processFile(context.Background(), filePath)
+	processFile(context.Background(), filePath)
+	if calls != 1 {
+		t.Fatalf("Expected unchanged note to be extracted once, got %d calls", calls)
+	}
+
+	os.WriteFile(filePath, []byte("# Notes\nTarea: investigar OSPF"), 0644)
+	processFile(context.Background(), filePath)
+	if calls != 2 {
+		t.Errorf("Expected edited note to be extracted again, got %d calls", calls)
+	}
+}
+
+func TestAddFrontmatterMarker(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"# Notes", "---\nprocesado_por_ia: true\n---\n\n# Notes"},
+		{"---\ntags: [redes]\n---\n# Notes", "---\nprocesado_por_ia: true\ntags: [redes]\n---\n# Notes"},
+		{"---\n---\n# Notes", "---\nprocesado_por_ia: true\n---\n# Notes"},
+	}
+	for _, c := range cases {
+		if got := addFrontmatterMarker(c.in); got != c.want {
+			t.Errorf("addFrontmatterMarker(%q) = %q, want %q", c.in, got, c.want)
+		}
 	}
 }
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// noteSection is a run of a note that starts at a Markdown heading (or at the
+// top of the note) and ends before the next heading. Sections are the unit of
+// incremental re-extraction: only sections whose hash was not seen the last
+// time the note was processed are sent to the LLM.
+type noteSection struct {
+	Text string
+	Hash string
+}
+
+// splitNoteSections splits content at ATX headings that are not inside fenced
+// code blocks. Sections containing only whitespace are dropped.
+func splitNoteSections(content string) []noteSection {
+	var sections []noteSection
+	var current strings.Builder
+	inFence := false
+
+	flush := func() {
+		text := current.String()
+		current.Reset()
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		sections = append(sections, noteSection{Text: text, Hash: contentHash([]byte(text))})
+	}
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(line, "#") {
+			flush()
+		}
+		current.WriteString(line)
+	}
+	flush()
+	return sections
+}
+
+// noteSectionHashes returns the hashes of the sections of path as of the last
+// time it was processed. Callers must hold mutex.
+func noteSectionHashes(path string) (map[string]bool, error) {
+	rows, err := db.Query("SELECT hash FROM note_sections WHERE path = ?", path)
+	if err != nil {
+		return nil, fmt.Errorf("error querying note sections: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]bool)
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("error scanning note section: %w", err)
+		}
+		hashes[hash] = true
+	}
+	return hashes, rows.Err()
+}
+
+func replaceNoteSections(path string, hashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM note_sections WHERE path = ?", path); err != nil {
+		return fmt.Errorf("error clearing note sections: %w", err)
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO note_sections(path, hash) VALUES(?, ?)", path, hash); err != nil {
+			return fmt.Errorf("error recording note section: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func tasksFromSource(path string) ([]Pendiente, error) {
+	rows, err := db.Query("SELECT "+taskColumns+" FROM tasks WHERE source_path = ?", path)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tasks from %s: %w", path, err)
+	}
+	defer rows.Close()
+
+	var tasks []Pendiente
+	for rows.Next() {
+		p, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning task row: %w", err)
+		}
+		tasks = append(tasks, p)
+	}
+	return tasks, rows.Err()
+}
+
+// maxSnippetLength caps the note excerpt stored with each task, in runes.
+const maxSnippetLength = 500
+
+// snippet trims a section to at most maxSnippetLength runes for storage as
+// the task's source excerpt.
+func snippet(text string) string {
+	text = strings.TrimSpace(text)
+	runes := []rune(text)
+	if len(runes) <= maxSnippetLength {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:maxSnippetLength])) + "…"
+}
+
+// normalizeDescription is the key used to recognise the same task across
+// extractions of a note.
+func normalizeDescription(description string) string {
+	return strings.ToLower(strings.Join(strings.Fields(description), " "))
+}
+
+func setTaskSource(id int, section, snippet string, removed bool) error {
+	_, err := db.Exec("UPDATE tasks SET source_section = ?, source_snippet = ?, removed_from_source = ? WHERE id = ?",
+		section, nullString(snippet), removed, id)
+	if err != nil {
+		return fmt.Errorf("error updating source of task %d: %w", id, err)
+	}
+	return nil
+}
+
+// setExtractedFields applies a new extraction of task id: the due date and
+// subject now read from the note, which may have been edited there, and the
+// section the task is in.
+func setExtractedFields(id int, prev, p Pendiente) error {
+	_, err := db.Exec(`UPDATE tasks SET text = ?, due_date = ?, subject = ?,
+		source_section = ?, source_snippet = ?, removed_from_source = 0 WHERE id = ?`,
+		formatTaskText(p.DueDate, p.Subject, prev.Description), nullString(p.DueDate), nullString(p.Subject),
+		p.SourceSection, nullString(p.SourceSnippet), id)
+	if err != nil {
+		return fmt.Errorf("error updating task %d: %w", id, err)
+	}
+	return nil
+}
+
+// reconcileNoteTasks merges the tasks extracted from the changed sections of
+// path with those already stored for it:
+//   - an extracted task is the same as an existing one with the same
+//     normalized description in the same section or, failing that, in a
+//     section that no longer exists, i.e. the one that was edited. It is not
+//     inserted again; the existing task gets the due date and subject read
+//     now, is moved to the new section and is un-flagged if needed. Tasks
+//     with the same text in two sections that are still there are distinct;
+//   - existing tasks whose section no longer exists and that were not
+//     extracted again are flagged removed_from_source, never deleted, since
+//     the user may already have checked them off.
+//
+// currentSections lists the hashes of every section of the note as it is now.
+// Callers must hold mutex.
+func reconcileNoteTasks(path string, extracted []Pendiente, currentSections []string) error {
+	existing, err := tasksFromSource(path)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]bool, len(currentSections))
+	for _, hash := range currentSections {
+		current[hash] = true
+	}
+	type taskKey struct{ section, description string }
+	bySection := make(map[taskKey][]Pendiente, len(existing))
+	stale := make(map[string][]Pendiente)
+	for _, p := range existing {
+		key := normalizeDescription(p.Description)
+		bySection[taskKey{p.SourceSection, key}] = append(bySection[taskKey{p.SourceSection, key}], p)
+		if !current[p.SourceSection] {
+			stale[key] = append(stale[key], p)
+		}
+	}
+
+	matched := make(map[int]bool)
+	// next returns the first candidate not matched yet.
+	next := func(candidates []Pendiente) (Pendiente, bool) {
+		for _, c := range candidates {
+			if !matched[c.ID] {
+				return c, true
+			}
+		}
+		return Pendiente{}, false
+	}
+
+	for _, p := range extracted {
+		key := normalizeDescription(p.Description)
+		prev, ok := next(bySection[taskKey{p.SourceSection, key}])
+		if !ok {
+			prev, ok = next(stale[key])
+		}
+		if ok {
+			matched[prev.ID] = true
+			if prev.SourceSection == p.SourceSection && !prev.RemovedFromSource &&
+				prev.DueDate == p.DueDate && prev.Subject == p.Subject {
+				continue
+			}
+			if err := setExtractedFields(prev.ID, prev, p); err != nil {
+				return err
+			}
+			if prev.RemovedFromSource || prev.DueDate != p.DueDate || prev.Subject != p.Subject {
+				publishTaskEvent(EventUpdated, prev.ID)
+			}
+			continue
+		}
+
+		id, err := insertTaskIntoDB(p)
+		if err != nil {
+			log.Printf("Error al insertar tarea '%s' en la DB: %v", p.Text, err)
+			continue
+		}
+		log.Printf("Tarea insertada: %s", p.Text)
+		publishTaskEvent(EventCreated, id)
+		matched[id] = true
+	}
+
+	for _, p := range existing {
+		if matched[p.ID] || p.RemovedFromSource || current[p.SourceSection] {
+			continue
+		}
+		if err := setTaskSource(p.ID, p.SourceSection, p.SourceSnippet, true); err != nil {
+			return err
+		}
+		log.Printf("Tarea ya no presente en %s: %s", path, p.Text)
+		publishTaskEvent(EventUpdated, p.ID)
+	}
+
+	return replaceNoteSections(path, currentSections)
+}
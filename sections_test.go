@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitNoteSections(t *testing.T) {
+	content := "---\ntags: [redes]\n---\nIntro\n# Clase\nTexto\n```bash\n# no es un encabezado\n```\n## Tarea\nInvestigar OSPF\n"
+	sections := splitNoteSections(content)
+
+	want := []string{
+		"---\ntags: [redes]\n---\nIntro\n",
+		"# Clase\nTexto\n```bash\n# no es un encabezado\n```\n",
+		"## Tarea\nInvestigar OSPF\n",
+	}
+	if len(sections) != len(want) {
+		t.Fatalf("Expected %d sections, got %d: %+v", len(want), len(sections), sections)
+	}
+	for i := range want {
+		if sections[i].Text != want[i] {
+			t.Errorf("section %d = %q, want %q", i, sections[i].Text, want[i])
+		}
+	}
+}
+
+func TestProcessFileIncrementalReextraction(t *testing.T) {
+	setupTestDB(t)
+
+	var prompts []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Messages[len(req.Messages)-1].Content
+		prompts = append(prompts, prompt)
+
+		answer := ollamaAnswer()
+		switch {
+		case strings.Contains(prompt, "VLAN"):
+			answer = ollamaAnswer(ExtractedTask{DueDate: "2026-10-20", Subject: "Redes", Description: "Configurar VLAN 10"})
+		case strings.Contains(prompt, "OSPF"):
+			answer = ollamaAnswer(ExtractedTask{DueDate: "2026-10-24", Subject: "Redes", Description: "Investigar OSPF"})
+		}
+		json.NewEncoder(w).Encode(answer)
+	}))
+	defer ts.Close()
+
+	useConfig(t, func(cfg *Config) { cfg.Ollama.URL = ts.URL })
+
+	note := filepath.Join(t.TempDir(), "Redes", time.Now().Format("2006-01-02")+" Redes.md")
+	os.MkdirAll(filepath.Dir(note), 0755)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(note, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		processFile(context.Background(), note)
+	}
+
+	write("# Clase\nConfigurar VLAN 10\n# Tarea\nInvestigar OSPF\n")
+	if len(prompts) != 2 {
+		t.Fatalf("Expected both sections to be extracted, got %d calls", len(prompts))
+	}
+
+	// Solo cambia la sección de la tarea.
+	write("# Clase\nConfigurar VLAN 10\n# Tarea\nInvestigar OSPF, una cuartilla\n")
+	if len(prompts) != 3 || strings.Contains(prompts[2], "VLAN") {
+		t.Fatalf("Expected only the edited section to be extracted, got %d calls", len(prompts))
+	}
+
+	tasks, err := tasksFromSource(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("Expected re-extraction not to duplicate tasks, got %d", len(tasks))
+	}
+
+	// Se elimina la sección de la tarea.
+	write("# Clase\nConfigurar VLAN 10\n")
+	if len(prompts) != 3 {
+		t.Errorf("Expected no extraction when only removing a section, got %d calls", len(prompts))
+	}
+
+	tasks, err = tasksFromSource(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range tasks {
+		removed := p.Description == "Investigar OSPF"
+		if p.RemovedFromSource != removed {
+			t.Errorf("Task %q: removed_from_source = %v, want %v", p.Description, p.RemovedFromSource, removed)
+		}
+		if p.SourcePath != note {
+			t.Errorf("Task %q: source_path = %q, want %q", p.Description, p.SourcePath, note)
+		}
+	}
+}
+
+func TestReconcileAppliesEditedDueDate(t *testing.T) {
+	setupTestDB(t)
+	useConfig(t, func(cfg *Config) { cfg.Extractor = "rules" })
+
+	note := filepath.Join(t.TempDir(), "Redes", time.Now().Format("2006-01-02")+" Redes.md")
+	os.MkdirAll(filepath.Dir(note), 0755)
+	write := func(content string) []Pendiente {
+		t.Helper()
+		if err := os.WriteFile(note, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		processFile(context.Background(), note)
+		tasks, err := tasksFromSource(note)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tasks
+	}
+
+	tasks := write("# Práctica\n- [ ] Entregar práctica 📅 2026-10-20\n")
+	if len(tasks) != 1 || tasks[0].DueDate != "2026-10-20" {
+		t.Fatalf("Unexpected tasks: %+v", tasks)
+	}
+	id := tasks[0].ID
+
+	// Se pospone la entrega en la nota.
+	tasks = write("# Práctica\n- [ ] Entregar práctica 📅 2026-10-27\n")
+	if len(tasks) != 1 {
+		t.Fatalf("Expected the edited task not to be duplicated, got %+v", tasks)
+	}
+	if p := tasks[0]; p.ID != id || p.DueDate != "2026-10-27" || p.RemovedFromSource || !strings.Contains(p.Text, "2026-10-27") {
+		t.Errorf("Expected the new due date on the same task, got %+v", p)
+	}
+}
+
+func TestReconcileKeepsSameTextInDifferentSections(t *testing.T) {
+	setupTestDB(t)
+	useConfig(t, func(cfg *Config) { cfg.Extractor = "rules" })
+
+	note := filepath.Join(t.TempDir(), "Redes", time.Now().Format("2006-01-02")+" Redes.md")
+	os.MkdirAll(filepath.Dir(note), 0755)
+	write := func(content string) []Pendiente {
+		t.Helper()
+		if err := os.WriteFile(note, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		processFile(context.Background(), note)
+		tasks, err := tasksFromSource(note)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tasks
+	}
+
+	tasks := write("# Clase 1\n- [ ] Leer el capítulo 3\n# Clase 2\n- [ ] Leer el capítulo 3\n")
+	if len(tasks) != 2 {
+		t.Fatalf("Expected one task per section, got %+v", tasks)
+	}
+
+	// Editar una sección no une ni marca como eliminada la otra tarea.
+	tasks = write("# Clase 1\n- [ ] Leer el capítulo 3\nTraer laptop.\n# Clase 2\n- [ ] Leer el capítulo 3\n")
+	if len(tasks) != 2 {
+		t.Fatalf("Expected both tasks to be kept, got %+v", tasks)
+	}
+	for _, p := range tasks {
+		if p.RemovedFromSource {
+			t.Errorf("Task %d flagged as removed: %+v", p.ID, p)
+		}
+	}
+}
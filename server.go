@@ -2,7 +2,9 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,18 +14,47 @@ import (
 	"time"
 
 	"database/sql"
+	"errors"
 	"path/filepath" // Add this import
+	"strconv"
 	_ "github.com/mattn/go-sqlite3" // Import go-sqlite3 library
 
 )
 
-const TimeFormat = "2006-01-02 15:04:05"
+const (
+	TimeFormat = "2006-01-02 15:04:05"
+	DateFormat = "2006-01-02"
+)
 
 type Pendiente struct {
 	ID          int        `json:"id"`
 	Text        string     `json:"text"`
+	DueDate     string     `json:"due_date,omitempty"`
+	Subject     string     `json:"subject,omitempty"`
+	Description string     `json:"description"`
 	Checked     bool       `json:"checked"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	// Provenance of extracted tasks; all empty for tasks created by hand.
+	// SourcePath is the note the task came from, SourceSubject the subject
+	// of its folder, renamed by the root's subjects if mapped, and NoteDate
+	// the note's date. SourceSnippet is the part of the note the LLM saw.
+	// RemovedFromSource is set when a later edit of the note no longer
+	// contains the task.
+	SourcePath        string `json:"source_path,omitempty"`
+	SourceSubject     string `json:"source_subject,omitempty"`
+	NoteDate          string `json:"note_date,omitempty"`
+	ExtractedBy       string `json:"extracted_by,omitempty"`
+	ExtractionModel   string `json:"extraction_model,omitempty"`
+	SourceSnippet     string `json:"source_snippet,omitempty"`
+	SourceSection     string `json:"-"`
+	RemovedFromSource bool   `json:"removed_from_source,omitempty"`
+
+	// Confidence is the model's own estimate (0-1) that this is a real task.
+	// Zero when the backend did not report one. PromptVersion identifies the
+	// prompt templates the task was extracted with.
+	Confidence    float64 `json:"confidence,omitempty"`
+	PromptVersion string  `json:"prompt_version,omitempty"`
 }
 
 var (
@@ -31,14 +62,68 @@ var (
 	mutex = &sync.RWMutex{}
 )
 
+// errTaskNotFound is returned by the DB helpers when no task has the given ID.
+var errTaskNotFound = errors.New("task not found")
+
+
 
 
 
+// parseTaskText splits a task in the "@{YYYY-MM-DD} / Materia / Descripcion"
+// convention into its parts. Text that does not follow the convention is
+// returned whole as the description.
+func parseTaskText(text string) (dueDate, subject, description string) {
+	rest := strings.TrimSpace(text)
+	if strings.HasPrefix(rest, "@{") {
+		end := strings.Index(rest, "}")
+		if end == -1 {
+			return "", "", rest
+		}
+		dateStr := strings.TrimSpace(rest[2:end])
+		if _, err := time.Parse(DateFormat, dateStr); err == nil {
+			dueDate = dateStr
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, "/"))
+	}
+
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" && strings.TrimSpace(parts[1]) != "" {
+		return dueDate, strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	}
+	return dueDate, "", rest
+}
+
+// formatTaskText is the inverse of parseTaskText: it rebuilds the raw task
+// line from its structured parts.
+func formatTaskText(dueDate, subject, description string) string {
+	var parts []string
+	if dueDate != "" {
+		parts = append(parts, "@{"+dueDate+"}")
+	}
+	if subject != "" {
+		parts = append(parts, subject)
+	}
+	parts = append(parts, description)
+	return strings.Join(parts, " / ")
+}
 
-func insertTaskIntoDB(p Pendiente) error {
-	stmt, err := db.Prepare("INSERT INTO tasks(text, checked, completed_at) VALUES(?, ?, ?)")
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// insertTaskIntoDB stores p and returns the ID assigned to it. If p has no
+// description, the structured fields are parsed from p.Text.
+func insertTaskIntoDB(p Pendiente) (int, error) {
+	if p.Description == "" {
+		p.DueDate, p.Subject, p.Description = parseTaskText(p.Text)
+	}
+
+	stmt, err := db.Prepare(`INSERT INTO tasks(text, due_date, subject, description, checked, completed_at,
+		source_path, source_section, source_subject, note_date, extracted_by, extraction_model, source_snippet, confidence, prompt_version)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
-		return fmt.Errorf("error preparing insert statement: %w", err)
+		return 0, fmt.Errorf("error preparing insert statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -50,15 +135,64 @@ func insertTaskIntoDB(p Pendiente) error {
 		completedAtStr.Valid = false
 	}
 
-	_, err = stmt.Exec(p.Text, p.Checked, completedAtStr)
+	res, err := stmt.Exec(p.Text, nullString(p.DueDate), nullString(p.Subject), p.Description, p.Checked, completedAtStr,
+		nullString(p.SourcePath), nullString(p.SourceSection), nullString(p.SourceSubject), nullString(p.NoteDate),
+		nullString(p.ExtractedBy), nullString(p.ExtractionModel), nullString(p.SourceSnippet),
+		sql.NullFloat64{Float64: p.Confidence, Valid: p.Confidence != 0}, nullString(p.PromptVersion))
 	if err != nil {
-		return fmt.Errorf("error executing insert statement: %w", err)
+		return 0, fmt.Errorf("error executing insert statement: %w", err)
 	}
-	return nil
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error reading inserted task id: %w", err)
+	}
+	return int(id), nil
+}
+
+const taskColumns = "id, text, due_date, subject, description, checked, completed_at, " +
+	"source_path, source_section, removed_from_source, source_subject, note_date, extracted_by, extraction_model, source_snippet, confidence, prompt_version"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTask(row rowScanner) (Pendiente, error) {
+	var p Pendiente
+	var dueDate, subject, completedAtStr, sourcePath, sourceSection sql.NullString
+	var sourceSubject, noteDate, extractedBy, extractionModel, sourceSnippet, promptVersion sql.NullString
+	var confidence sql.NullFloat64
+	if err := row.Scan(&p.ID, &p.Text, &dueDate, &subject, &p.Description, &p.Checked, &completedAtStr,
+		&sourcePath, &sourceSection, &p.RemovedFromSource,
+		&sourceSubject, &noteDate, &extractedBy, &extractionModel, &sourceSnippet, &confidence, &promptVersion); err != nil {
+		return p, err
+	}
+	p.DueDate = dueDate.String
+	p.Subject = subject.String
+	p.SourcePath = sourcePath.String
+	p.SourceSection = sourceSection.String
+	p.SourceSubject = sourceSubject.String
+	p.NoteDate = noteDate.String
+	p.ExtractedBy = extractedBy.String
+	p.ExtractionModel = extractionModel.String
+	p.SourceSnippet = sourceSnippet.String
+	p.Confidence = confidence.Float64
+	p.PromptVersion = promptVersion.String
+
+	if completedAtStr.Valid {
+		t, err := time.Parse(TimeFormat, completedAtStr.String)
+		if err != nil {
+			log.Printf("Error parsing completed_at time: %v", err)
+			// Continue with nil if parsing fails to avoid blocking other tasks
+		} else {
+			p.CompletedAt = &t
+		}
+	}
+	return p, nil
 }
 
 func getTasksFromDB() ([]Pendiente, error) {
-	rows, err := db.Query("SELECT id, text, checked, completed_at FROM tasks ORDER BY id DESC")
+	rows, err := db.Query("SELECT " + taskColumns + " FROM tasks ORDER BY id DESC")
 	if err != nil {
 		return nil, fmt.Errorf("error querying tasks: %w", err)
 	}
@@ -66,28 +200,27 @@ func getTasksFromDB() ([]Pendiente, error) {
 
 	var tasks []Pendiente
 	for rows.Next() {
-		var p Pendiente
-		var completedAtStr sql.NullString
-		err := rows.Scan(&p.ID, &p.Text, &p.Checked, &completedAtStr)
+		p, err := scanTask(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning task row: %w", err)
 		}
-
-		if completedAtStr.Valid {
-			t, err := time.Parse(TimeFormat, completedAtStr.String)
-			if err != nil {
-				log.Printf("Error parsing completed_at time: %v", err)
-				// Continue with nil if parsing fails to avoid blocking other tasks
-			} else {
-				p.CompletedAt = &t
-			}
-		}
 		tasks = append(tasks, p)
 	}
 
 	return tasks, nil
 }
 
+func getTaskFromDB(id int) (Pendiente, error) {
+	p, err := scanTask(db.QueryRow("SELECT "+taskColumns+" FROM tasks WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, errTaskNotFound
+	}
+	if err != nil {
+		return p, fmt.Errorf("error querying task %d: %w", id, err)
+	}
+	return p, nil
+}
+
 func getPendientesHandler(w http.ResponseWriter, r *http.Request) {
 	mutex.RLock()
 	defer mutex.RUnlock()
@@ -111,6 +244,7 @@ type UpdateRequest struct {
 
 func updateTaskInDB(id int, checked bool) error {
 	var stmt *sql.Stmt
+	var res sql.Result
 	var err error
 
 	if checked {
@@ -122,7 +256,7 @@ func updateTaskInDB(id int, checked bool) error {
 		defer stmt.Close()
 
 		now := time.Now()
-		_, err = stmt.Exec(checked, now.Format(TimeFormat), id)
+		res, err = stmt.Exec(checked, now.Format(TimeFormat), id)
 	} else {
 		// Update checked status and set completed_at to NULL
 		stmt, err = db.Prepare("UPDATE tasks SET checked = ?, completed_at = NULL WHERE id = ?")
@@ -131,12 +265,25 @@ func updateTaskInDB(id int, checked bool) error {
 		}
 		defer stmt.Close()
 
-		_, err = stmt.Exec(checked, id)
+		res, err = stmt.Exec(checked, id)
 	}
 
 	if err != nil {
 		return fmt.Errorf("error executing update statement: %w", err)
 	}
+	return checkTaskAffected(res)
+}
+
+// checkTaskAffected turns an UPDATE or DELETE that matched no rows into
+// errTaskNotFound.
+func checkTaskAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error reading affected rows: %w", err)
+	}
+	if n == 0 {
+		return errTaskNotFound
+	}
 	return nil
 }
 
@@ -151,15 +298,265 @@ func updatePendienteHandler(w http.ResponseWriter, r *http.Request) {
 	defer mutex.Unlock()
 
 	if err := updateTaskInDB(req.ID, req.Checked); err != nil {
+		if errors.Is(err, errTaskNotFound) {
+			http.Error(w, fmt.Sprintf("Tarea %d no encontrada", req.ID), http.StatusNotFound)
+			return
+		}
 		log.Printf("¡ATENCIÓN! Error al actualizar tarea en la DB: %v", err)
 		http.Error(w, "Error interno al actualizar la tarea", http.StatusInternalServerError)
 		return
 	}
+	publishTaskEvent(EventUpdated, req.ID)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// TaskInput is the request body of POST /tasks and PATCH /tasks/{id}. Nil
+// fields are left untouched. When only Text is given the structured fields are
+// parsed from it; when structured fields are given without Text, Text is
+// rebuilt from them.
+type TaskInput struct {
+	Text        *string `json:"text"`
+	DueDate     *string `json:"due_date"`
+	Subject     *string `json:"subject"`
+	Description *string `json:"description"`
+	Checked     *bool   `json:"checked"`
+}
+
+func (in TaskInput) applyTo(p *Pendiente) error {
+	structured := in.DueDate != nil || in.Subject != nil || in.Description != nil
+
+	if in.Text != nil {
+		p.Text = strings.TrimSpace(*in.Text)
+		if !structured {
+			p.DueDate, p.Subject, p.Description = parseTaskText(p.Text)
+		}
+	}
+	if in.DueDate != nil {
+		dueDate := strings.TrimSpace(*in.DueDate)
+		if dueDate != "" {
+			if _, err := time.Parse(DateFormat, dueDate); err != nil {
+				return fmt.Errorf("due_date debe tener el formato YYYY-MM-DD: %q", dueDate)
+			}
+		}
+		p.DueDate = dueDate
+	}
+	if in.Subject != nil {
+		p.Subject = strings.TrimSpace(*in.Subject)
+	}
+	if in.Description != nil {
+		p.Description = strings.TrimSpace(*in.Description)
+	}
+	if structured && in.Text == nil {
+		p.Text = formatTaskText(p.DueDate, p.Subject, p.Description)
+	}
+	if p.Description == "" {
+		return errors.New("la tarea necesita text o description")
+	}
+
+	if in.Checked != nil && *in.Checked != p.Checked {
+		p.Checked = *in.Checked
+		p.CompletedAt = nil
+		if p.Checked {
+			now := time.Now()
+			p.CompletedAt = &now
+		}
+	}
+	return nil
+}
+
+// updateTaskFieldsInDB overwrites every column of the task with p.ID.
+func updateTaskFieldsInDB(p Pendiente) error {
+	var completedAtStr sql.NullString
+	if p.CompletedAt != nil {
+		completedAtStr = nullString(p.CompletedAt.Format(TimeFormat))
+	}
+
+	res, err := db.Exec("UPDATE tasks SET text = ?, due_date = ?, subject = ?, description = ?, checked = ?, completed_at = ? WHERE id = ?",
+		p.Text, nullString(p.DueDate), nullString(p.Subject), p.Description, p.Checked, completedAtStr, p.ID)
+	if err != nil {
+		return fmt.Errorf("error executing update statement: %w", err)
+	}
+	return checkTaskAffected(res)
+}
+
+func deleteTaskFromDB(id int) error {
+	res, err := db.Exec("DELETE FROM tasks WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("error executing delete statement: %w", err)
+	}
+	return checkTaskAffected(res)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// taskIDFromPath parses the {id} wildcard, answering 400 when it is invalid.
+func taskIDFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("ID de tarea inválido: %q", r.PathValue("id")), http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeTaskError maps errors from the DB helpers to HTTP responses.
+func writeTaskError(w http.ResponseWriter, id int, err error) {
+	if errors.Is(err, errTaskNotFound) {
+		http.Error(w, fmt.Sprintf("Tarea %d no encontrada", id), http.StatusNotFound)
+		return
+	}
+	log.Printf("Error en la DB para la tarea %d: %v", id, err)
+	http.Error(w, "Error interno al acceder a la tarea", http.StatusInternalServerError)
+}
+
+func createTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var in TaskInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var p Pendiente
+	if err := in.applyTo(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	id, err := insertTaskIntoDB(p)
+	if err != nil {
+		log.Printf("Error al crear tarea en la DB: %v", err)
+		http.Error(w, "Error interno al crear la tarea", http.StatusInternalServerError)
+		return
+	}
+	p, err = getTaskFromDB(id)
+	if err != nil {
+		writeTaskError(w, id, err)
+		return
+	}
+
+	emitTaskEvent(TaskEvent{Type: EventCreated, Task: p})
+
+	w.Header().Set("Location", fmt.Sprintf("/tasks/%d", id))
+	writeJSON(w, http.StatusCreated, p)
+}
+
+func getTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := taskIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	p, err := getTaskFromDB(id)
+	if err != nil {
+		writeTaskError(w, id, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func patchTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := taskIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var in TaskInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	p, err := getTaskFromDB(id)
+	if err != nil {
+		writeTaskError(w, id, err)
+		return
+	}
+	if err := in.applyTo(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := updateTaskFieldsInDB(p); err != nil {
+		writeTaskError(w, id, err)
+		return
+	}
+	if p, err = getTaskFromDB(id); err != nil {
+		writeTaskError(w, id, err)
+		return
+	}
+	emitTaskEvent(TaskEvent{Type: EventUpdated, Task: p})
+	writeJSON(w, http.StatusOK, p)
+}
+
+func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := taskIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	p, err := getTaskFromDB(id)
+	if err != nil {
+		writeTaskError(w, id, err)
+		return
+	}
+	if err := deleteTaskFromDB(id); err != nil {
+		writeTaskError(w, id, err)
+		return
+	}
+	emitTaskEvent(TaskEvent{Type: EventDeleted, Task: p})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// withCORS lets the browser front-ends call the API from any origin and
+// answers preflight requests before they reach the method-aware routes.
+func withCORS(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// newRouter registers every API route.
+func newRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pendientes", getPendientesHandler)
+	mux.HandleFunc("/update", updatePendienteHandler)
+
+	mux.HandleFunc("GET /tasks", listTasksHandler)
+	mux.HandleFunc("POST /tasks", createTaskHandler)
+	mux.HandleFunc("GET /tasks/{id}", getTaskHandler)
+	mux.HandleFunc("PATCH /tasks/{id}", patchTaskHandler)
+	mux.HandleFunc("DELETE /tasks/{id}", deleteTaskHandler)
+
+	mux.HandleFunc("GET /ws", wsHandler)
+	mux.HandleFunc("GET /events", sseHandler)
+
+	mux.HandleFunc("POST /backfill", backfillHandler)
+	return mux
+}
+
 // migrateMarkdownToSQLite reads pendientes.md and populates the SQLite database.
 func migrateMarkdownToSQLite(markdownFilePath string) error {
 	file, err := os.Open(markdownFilePath)
@@ -217,7 +614,7 @@ func migrateMarkdownToSQLite(markdownFilePath string) error {
 				Checked:     fl.Checked,
 				CompletedAt: fl.CompletedAt,
 			}
-			if err := insertTaskIntoDB(task); err != nil {
+			if _, err := insertTaskIntoDB(task); err != nil {
 				log.Printf("Error inserting migrated task '%s': %v", task.Text, err)
 			}
 		}
@@ -225,39 +622,120 @@ func migrateMarkdownToSQLite(markdownFilePath string) error {
 	return scanner.Err()
 }
 
-func main() {
-	// Initialize SQLite
-	var err error
+// defaultDBPath returns the location of tasks.db inside the user's data
+// directory, creating the directory if needed.
+func defaultDBPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		log.Fatalf("Error getting user home directory: %v", err)
+		return "", fmt.Errorf("error getting user home directory: %w", err)
 	}
 	dataDir := filepath.Join(homeDir, ".local", "share", "tareasgenerador")
-	dbPath := filepath.Join(dataDir, "tasks.db")
 
 	// Create data directory if it doesn't exist
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Fatalf("Error creating data directory: %v", err)
+		return "", fmt.Errorf("error creating data directory: %w", err)
 	}
+	return filepath.Join(dataDir, "tasks.db"), nil
+}
 
+func openDB(dbPath string) error {
+	var err error
 	db, err = sql.Open("sqlite3", dbPath)
 	if err != nil {
-		log.Fatalf("Error opening database: %v", err)
+		return fmt.Errorf("error opening database: %w", err)
+	}
+	return nil
+}
+
+// initDB opens the SQLite database at dbPath and applies any pending schema
+// migrations.
+func initDB(dbPath string) error {
+	if err := openDB(dbPath); err != nil {
+		return err
+	}
+	if _, err := applyMigrations(); err != nil {
+		return fmt.Errorf("error applying migrations: %w", err)
+	}
+	return nil
+}
+
+// runCommand executes a command-line subcommand instead of starting the
+// server.
+func runCommand(args []string, configPath string) error {
+	if args[0] == "config" {
+		return runConfigCommand(args[1:], configPath)
+	}
+
+	dbPath, err := defaultDBPath()
+	if err != nil {
+		return err
+	}
+	if err := openDB(dbPath); err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "migrate":
+		return runMigrateCommand(args[1:])
+	case "quarantine":
+		if _, err := applyMigrations(); err != nil {
+			return err
+		}
+		return runQuarantineCommand(args[1:])
+	case "cache":
+		if _, err := applyMigrations(); err != nil {
+			return err
+		}
+		return runCacheCommand(args[1:])
+	case "backfill":
+		if _, err := applyMigrations(); err != nil {
+			return err
+		}
+		return runBackfillCommand(args[1:], dbPath)
+	default:
+		return fmt.Errorf("comando desconocido: %s", args[0])
+	}
+}
+
+func main() {
+	configPath := flag.String("config", "", "archivo de configuración TOML o YAML (por defecto $XDG_CONFIG_HOME/tareasgenerador/config.toml)")
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		if flag.Arg(0) != "config" {
+			if err := loadAndApplyConfig(*configPath); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := runCommand(flag.Args(), *configPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := loadAndApplyConfig(*configPath); err != nil {
+		log.Fatal(err)
+	}
+
+	// Initialize SQLite
+	dbPath, err := defaultDBPath()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := initDB(dbPath); err != nil {
+		log.Fatalf("Error initializing database: %v", err)
 	}
 	defer db.Close()
 
-	sqlStmt := `
-	CREATE TABLE IF NOT EXISTS tasks (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		text TEXT NOT NULL,
-		checked BOOLEAN NOT NULL DEFAULT FALSE,
-		completed_at TEXT
-	);
-	`
-	_, err = db.Exec(sqlStmt)
+	// Mientras el servicio corre, el comando backfill le delega el trabajo
+	// en lugar de procesar notas por su cuenta.
+	unlock, err := lockNotes(dbPath)
 	if err != nil {
-		log.Fatalf("Error creating tasks table: %v", err)
+		log.Fatal(err)
 	}
+	defer unlock()
 
 	// Check if the database is empty before migrating
 	var count int
@@ -277,34 +755,32 @@ func main() {
 
 	log.Println("Servidor de horarios iniciado. Sirviendo en http://localhost:8080")
 
-	// Iniciar escáner de carpetas en segundo plano
+	ctx := context.Background()
+
+	// Vigilar los directorios de notas para procesar cada nota en cuanto el
+	// editor termine de guardarla. La configuración se recarga sin reiniciar
+	// el servidor.
+	notes := &notesService{ctx: ctx, configPath: *configPath}
+	notes.restartWatchers()
+	defer notes.close()
+	go notes.watchConfig()
+
+	// Las notas cuya extracción falló se reintentan con espera creciente.
+	go runRetryQueue(ctx)
+
+	// El escaneo completo periódico queda como red de seguridad para eventos
+	// que inotify pudiera haber perdido.
 	go func() {
 		log.Println("Iniciando escáner de carpetas inicial...")
-		scanAndProcessDirectory(defaultScanDir)
+		notes.scanAll()
 		ticker := time.NewTicker(1 * time.Hour)
 		for range ticker.C {
 			log.Println("Ejecutando escaneo periódico...")
-			scanAndProcessDirectory(defaultScanDir)
+			notes.scanAll()
 		}
 	}()
 
-	corsHandler := func(h http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			if r.Method == http.MethodOptions {
-				w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-				return
-			}
-			h(w, r)
-		}
-	}
-
-	http.HandleFunc("/pendientes", corsHandler(getPendientesHandler))
-	http.HandleFunc("/update", corsHandler(updatePendienteHandler))
-
-
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	if err := http.ListenAndServe(":8080", withCORS(newRouter())); err != nil {
 		log.Fatalf("No se pudo iniciar el servidor: %v", err)
 	}
 }
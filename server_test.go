@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func doRequest(t *testing.T, handler http.Handler, method, url, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTaskCRUD(t *testing.T) {
+	setupTestDB(t)
+	router := withCORS(newRouter())
+
+	rec := doRequest(t, router, "POST", "/tasks", `{"text": "@{2026-11-01} / Redes / Investigar OSPF"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /tasks: expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var created Pendiente
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == 0 || created.DueDate != "2026-11-01" || created.Subject != "Redes" || created.Description != "Investigar OSPF" {
+		t.Errorf("Unexpected created task: %+v", created)
+	}
+
+	taskURL := fmt.Sprintf("/tasks/%d", created.ID)
+	rec = doRequest(t, router, "PATCH", taskURL, `{"subject": "Redes II", "checked": true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH %s: expected 200, got %d: %s", taskURL, rec.Code, rec.Body)
+	}
+	var patched Pendiente
+	if err := json.NewDecoder(rec.Body).Decode(&patched); err != nil {
+		t.Fatal(err)
+	}
+	if patched.Text != "@{2026-11-01} / Redes II / Investigar OSPF" {
+		t.Errorf("Text was not rebuilt from structured fields: %q", patched.Text)
+	}
+	if !patched.Checked || patched.CompletedAt == nil {
+		t.Errorf("Task was not marked completed: %+v", patched)
+	}
+
+	rec = doRequest(t, router, "GET", taskURL, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: expected 200, got %d", taskURL, rec.Code)
+	}
+
+	rec = doRequest(t, router, "DELETE", taskURL, "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE %s: expected 204, got %d", taskURL, rec.Code)
+	}
+
+	for _, method := range []string{"GET", "PATCH", "DELETE"} {
+		rec = doRequest(t, router, method, taskURL, `{"checked": false}`)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s after delete: expected 404, got %d", method, taskURL, rec.Code)
+		}
+	}
+}
+
+func TestTaskCRUDValidation(t *testing.T) {
+	setupTestDB(t)
+	router := withCORS(newRouter())
+
+	cases := []struct {
+		method, url, body string
+		want              int
+	}{
+		{"POST", "/tasks", `{}`, http.StatusBadRequest},
+		{"POST", "/tasks", `{"description": "x", "due_date": "mañana"}`, http.StatusBadRequest},
+		{"POST", "/tasks", `not json`, http.StatusBadRequest},
+		{"GET", "/tasks/abc", "", http.StatusBadRequest},
+		{"PUT", "/tasks/1", "", http.StatusMethodNotAllowed},
+		{"OPTIONS", "/tasks/1", "", http.StatusOK},
+	}
+	for _, c := range cases {
+		rec := doRequest(t, router, c.method, c.url, c.body)
+		if rec.Code != c.want {
+			t.Errorf("%s %s %s: expected %d, got %d", c.method, c.url, c.body, c.want, rec.Code)
+		}
+	}
+}
+
+func TestUpdatePendienteUnknownID(t *testing.T) {
+	setupTestDB(t)
+
+	rec := doRequest(t, newRouter(), "POST", "/update", `{"id": 999, "checked": true}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown task, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const sseHeartbeat = 30 * time.Second
+
+// writeSSE sends one event in text/event-stream format.
+func writeSSE(w http.ResponseWriter, ev TaskEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, data)
+	return err
+}
+
+// lastEventID reads the resume position from the Last-Event-ID header, or
+// from the last_event_id query parameter for clients that cannot set headers.
+func lastEventID(r *http.Request) (int64, bool, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("Last-Event-ID inválido: %q", v)
+	}
+	return id, true, nil
+}
+
+// sseHandler streams the same notifications as /ws as Server-Sent Events.
+// Clients that reconnect with Last-Event-ID first receive every stored event
+// they missed. If some of them were already pruned a "reset" event is sent so
+// the client knows to reload the full list.
+func sseHandler(w http.ResponseWriter, r *http.Request) {
+	after, resume, err := lastEventID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	// Suscribirse antes de leer el historial para no perder eventos que
+	// lleguen mientras tanto; los duplicados se descartan por Seq.
+	ch, unsubscribe := events.subscribe()
+	defer unsubscribe()
+
+	var backlog []TaskEvent
+	gap := false
+	if resume {
+		mutex.RLock()
+		backlog, gap, err = taskEventsSince(after)
+		mutex.RUnlock()
+		if err != nil {
+			log.Printf("Error leyendo eventos pendientes: %v", err)
+			http.Error(w, "Error interno al leer eventos", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if gap {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, ev := range backlog {
+		if err := writeSSE(w, ev); err != nil {
+			return
+		}
+		after = ev.Seq
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			if resume && ev.Seq != 0 && ev.Seq <= after {
+				continue
+			}
+			if err := writeSSE(w, ev); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
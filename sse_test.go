@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSSEIDs reads n events from the stream and returns their "id:" fields.
+func readSSEIDs(t *testing.T, r *bufio.Reader, n int) []string {
+	t.Helper()
+	var ids []string
+	for len(ids) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Reading SSE stream: %v (got ids %v)", err, ids)
+		}
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			ids = append(ids, strings.TrimSpace(id))
+		}
+	}
+	return ids
+}
+
+func TestSSEResumesFromLastEventID(t *testing.T) {
+	setupTestDB(t)
+	ts := httptest.NewServer(withCORS(newRouter()))
+	defer ts.Close()
+
+	mutex.Lock()
+	for _, text := range []string{"A", "B", "C"} {
+		id, err := insertTaskIntoDB(Pendiente{Text: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		publishTaskEvent(EventCreated, id)
+	}
+	mutex.Unlock()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Unexpected Content-Type %q", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	if ids := readSSEIDs(t, reader, 2); ids[0] != "2" || ids[1] != "3" {
+		t.Fatalf("Expected replay of events 2 and 3, got %v", ids)
+	}
+
+	mutex.Lock()
+	publishTaskEvent(EventUpdated, 1)
+	mutex.Unlock()
+
+	if ids := readSSEIDs(t, reader, 1); ids[0] != "4" {
+		t.Errorf("Expected live event 4, got %v", ids)
+	}
+}
+
+func TestUnstoredEventsAreNotPublished(t *testing.T) {
+	setupTestDB(t)
+	ch, unsubscribe := events.subscribe()
+	defer unsubscribe()
+
+	mutex.Lock()
+	id, err := insertTaskIntoDB(Pendiente{Text: "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("DROP TABLE task_events")
+	publishTaskEvent(EventCreated, id)
+	mutex.Unlock()
+
+	select {
+	case ev := <-ch:
+		t.Errorf("Event published without being stored: %+v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSSERejectsInvalidLastEventID(t *testing.T) {
+	setupTestDB(t)
+
+	rec := doRequest(t, newRouter(), "GET", "/events?last_event_id=abc", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExtractedTask is one task as returned by a backend. Confidence is the
+// model's own estimate between 0 and 1; backends without structured output
+// leave it at 0.
+type ExtractedTask struct {
+	DueDate     string  `json:"due_date"`
+	Subject     string  `json:"subject"`
+	Description string  `json:"description"`
+	Confidence  float64 `json:"confidence"`
+}
+
+// StructuredExtractor is implemented by backends that can constrain the model
+// to taskListSchema. ExtractJSON returns the raw JSON answer, which is decoded
+// and validated by extractNoteTasks. Backends that only implement
+// TaskExtractor have their markdown answer parsed with parseMarkdownAnswer
+// instead.
+type StructuredExtractor interface {
+	TaskExtractor
+	ExtractJSON(ctx context.Context, req ExtractionRequest) (string, error)
+}
+
+// taskListSchema is the JSON schema every structured answer must match.
+var taskListSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"tasks": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"due_date":    map[string]any{"type": "string", "description": "Fecha de entrega en formato YYYY-MM-DD"},
+					"subject":     map[string]any{"type": "string"},
+					"description": map[string]any{"type": "string"},
+					"confidence":  map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+				},
+				"required":             []string{"due_date", "subject", "description", "confidence"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"tasks"},
+	"additionalProperties": false,
+}
+
+// decodeStructuredTasks parses a structured answer. Some models wrap the JSON
+// in a code fence even when asked not to, so that is tolerated.
+func decodeStructuredTasks(raw string) ([]ExtractedTask, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "```") {
+		raw = strings.TrimPrefix(raw, "```json")
+		raw = strings.TrimPrefix(raw, "```")
+		raw = strings.TrimSuffix(strings.TrimSpace(raw), "```")
+	}
+
+	var answer struct {
+		Tasks []ExtractedTask `json:"tasks"`
+	}
+	if err := json.Unmarshal([]byte(raw), &answer); err != nil {
+		return nil, fmt.Errorf("respuesta JSON inválida: %w", err)
+	}
+	return answer.Tasks, nil
+}
+
+// toPendiente builds the task to store. Due dates that do not parse are
+// dropped, as parseTaskText does; validated tasks always have one.
+func (t ExtractedTask) toPendiente() Pendiente {
+	dueDate := strings.TrimSpace(t.DueDate)
+	if _, err := time.Parse(DateFormat, dueDate); err != nil {
+		dueDate = ""
+	}
+	subject := strings.TrimSpace(t.Subject)
+	description := strings.TrimSpace(t.Description)
+	return Pendiente{
+		Text:        formatTaskText(dueDate, subject, description),
+		DueDate:     dueDate,
+		Subject:     subject,
+		Description: description,
+		Confidence:  t.Confidence,
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeStructuredTasks(t *testing.T) {
+	raw := "```json\n{\"tasks\": [{\"due_date\": \"2026-10-24\", \"subject\": \"Redes\", \"description\": \"Investigar OSPF\", \"confidence\": 0.7}]}\n```"
+	tasks, err := decodeStructuredTasks(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Subject != "Redes" || tasks[0].Confidence != 0.7 {
+		t.Errorf("Unexpected tasks: %+v", tasks)
+	}
+
+	if _, err := decodeStructuredTasks("- [ ] @{2026-10-24} / Redes / Investigar OSPF"); err == nil {
+		t.Error("Expected error for a markdown answer")
+	}
+}
+
+func TestExtractedTaskToPendiente(t *testing.T) {
+	p := ExtractedTask{DueDate: "el viernes", Subject: " Redes ", Description: "Investigar OSPF", Confidence: 0.5}.toPendiente()
+	if p.DueDate != "" || p.Subject != "Redes" || p.Text != "Redes / Investigar OSPF" || p.Confidence != 0.5 {
+		t.Errorf("Unexpected task: %+v", p)
+	}
+}
+
+func TestExtractNoteTasksMarkdownFallback(t *testing.T) {
+	setupTestDB(t)
+	fake := &fakeExtractor{answer: "- [ ] @{2026-10-24} / Redes / Investigar OSPF"}
+	tasks, err := extractNoteTasks(context.Background(), configSnapshot(), fake, ExtractionRequest{Now: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].DueDate != "2026-10-24" || tasks[0].Description != "Investigar OSPF" {
+		t.Errorf("Unexpected tasks: %+v", tasks)
+	}
+
+	fake.answer = ""
+	if _, err := extractNoteTasks(context.Background(), configSnapshot(), fake, ExtractionRequest{Content: "otra nota", Now: time.Now()}); err == nil {
+		t.Error("Expected error for an empty answer")
+	}
+}
+
+func TestExtractNoteTasksRepairsInvalidJSON(t *testing.T) {
+	setupTestDB(t)
+	var requests []OllamaRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		if len(requests) == 1 {
+			json.NewEncoder(w).Encode(OllamaResponse{Message: Message{Role: "assistant", Content: "Aquí tienes: {"}})
+			return
+		}
+		json.NewEncoder(w).Encode(ollamaAnswer(ExtractedTask{DueDate: "2026-10-24", Subject: "Redes", Description: "Investigar OSPF", Confidence: 0.9}))
+	}))
+	defer ts.Close()
+
+	ext := &ollamaExtractor{url: ts.URL, model: "x", client: http.DefaultClient}
+	tasks, err := extractNoteTasks(context.Background(), configSnapshot(), ext, ExtractionRequest{Now: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || len(requests) != 2 {
+		t.Fatalf("Expected one repaired task after 2 requests, got %+v after %d", tasks, len(requests))
+	}
+	repair := requests[1]
+	if repair.Format == nil || !strings.Contains(repair.Messages[len(repair.Messages)-1].Content, "JSON") {
+		t.Errorf("Repair request lost the schema or the decode error: %+v", repair.Messages[len(repair.Messages)-1])
+	}
+}
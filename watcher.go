@@ -0,0 +1,192 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// noteWatcher watches a notes tree with inotify and calls process for each
+// Markdown file once it has stopped changing for the debounce period. New
+// directories, such as a freshly created subject folder, are watched as soon
+// as they appear. Files and directories for which ignore returns true are
+// left alone; when an ignore file changes, the folders it no longer ignores
+// are watched and their notes processed.
+type noteWatcher struct {
+	root     string
+	watcher  *fsnotify.Watcher
+	debounce time.Duration
+	ignore   func(path string, isDir bool) bool
+	process  func(path string)
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+func newNoteWatcher(root string, debounce time.Duration, ignore func(path string, isDir bool) bool, process func(path string)) (*noteWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	nw := &noteWatcher{
+		root:     root,
+		watcher:  w,
+		debounce: debounce,
+		ignore:   ignore,
+		process:  process,
+		timers:   make(map[string]*time.Timer),
+	}
+	if err := nw.addTree(root, false); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return nw, nil
+}
+
+// addTree watches dir and every directory below it. When schedule is true the
+// Markdown files found are queued too, which covers folders moved into the
+// tree with notes already inside.
+func (nw *noteWatcher) addTree(dir string, schedule bool) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if nw.ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if err := nw.watcher.Add(path); err != nil {
+				log.Printf("No se pudo vigilar %s: %v", path, err)
+			}
+			return nil
+		}
+		if schedule && isNoteFile(path) {
+			nw.schedule(path)
+		}
+		return nil
+	})
+}
+
+func (nw *noteWatcher) ignored(path string, isDir bool) bool {
+	return nw.ignore != nil && nw.ignore(path, isDir)
+}
+
+func isNoteFile(path string) bool {
+	return strings.HasSuffix(filepath.Base(path), ".md")
+}
+
+// schedule (re)starts the debounce timer for path.
+func (nw *noteWatcher) schedule(path string) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	if t, ok := nw.timers[path]; ok {
+		t.Reset(nw.debounce)
+		return
+	}
+	nw.timers[path] = time.AfterFunc(nw.debounce, func() {
+		nw.mu.Lock()
+		delete(nw.timers, path)
+		nw.mu.Unlock()
+		nw.process(path)
+	})
+}
+
+func (nw *noteWatcher) cancel(path string) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+
+	if t, ok := nw.timers[path]; ok {
+		t.Stop()
+		delete(nw.timers, path)
+	}
+}
+
+func (nw *noteWatcher) handle(ev fsnotify.Event) {
+	info, statErr := os.Stat(ev.Name)
+	isDir := statErr == nil && info.IsDir()
+	if nw.ignored(ev.Name, isDir) {
+		return
+	}
+	if filepath.Base(ev.Name) == ignoreFileName {
+		// Las carpetas que ya no se ignoran se vigilan desde ahora; las
+		// que pasan a ignorarse siguen vigiladas, pero sus eventos se
+		// descartan.
+		if err := nw.addTree(filepath.Dir(ev.Name), true); err != nil {
+			log.Printf("Error vigilando %s: %v", filepath.Dir(ev.Name), err)
+		}
+		return
+	}
+	switch {
+	case ev.Has(fsnotify.Create):
+		if statErr != nil {
+			return
+		}
+		if isDir {
+			if err := nw.addTree(ev.Name, true); err != nil {
+				log.Printf("Error vigilando nuevo directorio %s: %v", ev.Name, err)
+			}
+			return
+		}
+		if isNoteFile(ev.Name) {
+			nw.schedule(ev.Name)
+		}
+	case ev.Has(fsnotify.Write):
+		if isNoteFile(ev.Name) {
+			nw.schedule(ev.Name)
+		}
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		// inotify deja de vigilar los directorios eliminados por sí solo; el
+		// nuevo nombre de un archivo renombrado llega como Create.
+		nw.cancel(ev.Name)
+	}
+}
+
+// run dispatches filesystem events until the watcher is closed.
+func (nw *noteWatcher) run() {
+	for {
+		select {
+		case ev, ok := <-nw.watcher.Events:
+			if !ok {
+				return
+			}
+			nw.handle(ev)
+		case err, ok := <-nw.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Error del vigilante de archivos: %v", err)
+		}
+	}
+}
+
+// pending returns the notes waiting for their debounce period to end.
+func (nw *noteWatcher) pending() []string {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	paths := make([]string, 0, len(nw.timers))
+	for path := range nw.timers {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// Close stops watching and discards pending debounce timers.
+func (nw *noteWatcher) Close() error {
+	nw.mu.Lock()
+	for path, t := range nw.timers {
+		t.Stop()
+		delete(nw.timers, path)
+	}
+	nw.mu.Unlock()
+	return nw.watcher.Close()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func expectProcessed(t *testing.T, processed <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-processed:
+		if got != want {
+			t.Fatalf("Expected %s to be processed, got %s", want, got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for %s to be processed", want)
+	}
+}
+
+func TestNoteWatcherDebouncesAndFollowsNewFolders(t *testing.T) {
+	root := t.TempDir()
+	processed := make(chan string, 10)
+
+	nw, err := newNoteWatcher(root, 100*time.Millisecond, nil, func(path string) { processed <- path })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Close()
+	go nw.run()
+
+	// Varias escrituras seguidas deben producir un solo procesamiento.
+	note := filepath.Join(root, "2026-10-15 Redes.md")
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(note, []byte("borrador"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	expectProcessed(t, processed, note)
+
+	// Archivos que no son notas se ignoran.
+	os.WriteFile(filepath.Join(root, "imagen.png"), []byte{}, 0644)
+
+	// Una carpeta de materia nueva se vigila en cuanto aparece.
+	subjectDir := filepath.Join(root, "BasesDeDatos")
+	if err := os.Mkdir(subjectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	nested := filepath.Join(subjectDir, "2026-10-16 BasesDeDatos.md")
+	if err := os.WriteFile(nested, []byte("Traer el diagrama ER"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectProcessed(t, processed, nested)
+
+	select {
+	case extra := <-processed:
+		t.Errorf("Unexpected extra processing of %s", extra)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
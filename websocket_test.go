@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func dialWS(t *testing.T, serverURL string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(serverURL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readEvent(t *testing.T, conn *websocket.Conn) TaskEvent {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var ev TaskEvent
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	return ev
+}
+
+func TestWebSocketBroadcastsTaskEvents(t *testing.T) {
+	setupTestDB(t)
+	ts := httptest.NewServer(withCORS(newRouter()))
+	defer ts.Close()
+
+	clients := []*websocket.Conn{dialWS(t, ts.URL), dialWS(t, ts.URL)}
+
+	// Esperar a que ambos clientes estén suscritos antes de publicar.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		events.mu.Lock()
+		n := len(events.subscribers)
+		events.mu.Unlock()
+		if n >= len(clients) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Only %d clients subscribed", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp, err := http.Post(ts.URL+"/tasks", "application/json", strings.NewReader(`{"text": "@{2026-11-01} / Redes / Investigar OSPF"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Post(ts.URL+"/update", "application/json", strings.NewReader(`{"id": 1, "checked": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	for i, conn := range clients {
+		created := readEvent(t, conn)
+		if created.Type != EventCreated || created.Task.Description != "Investigar OSPF" {
+			t.Errorf("client %d: unexpected first event %+v", i, created)
+		}
+		updated := readEvent(t, conn)
+		if updated.Type != EventUpdated || updated.Task.ID != created.Task.ID || !updated.Task.Checked {
+			t.Errorf("client %d: unexpected second event %+v", i, updated)
+		}
+	}
+}
+
+func TestEventHubDropsSlowSubscriber(t *testing.T) {
+	hub := newEventHub()
+	ch, unsubscribe := hub.subscribe()
+	defer unsubscribe()
+
+	for i := 0; i <= eventBufferSize; i++ {
+		hub.publish(TaskEvent{Type: EventCreated, Task: Pendiente{ID: i}})
+	}
+
+	received := 0
+	for range ch {
+		received++
+	}
+	if received != eventBufferSize {
+		t.Errorf("Expected %d buffered events before drop, got %d", eventBufferSize, received)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// backendSlots caps the calls in flight to each backend at its Concurrency,
+// shared by the scans, the watchers and the retry queue, and backendLimits
+// spaces them out to its RPM, as a token bucket with room for a single
+// request. Both are rebuilt after a configuration change.
+var (
+	backendSlotsMu sync.Mutex
+	backendSlots   = map[string]chan struct{}{}
+	backendLimits  = map[string]*rate.Limiter{}
+)
+
+// acquireBackend waits for name's rate limit and for a free slot among its
+// concurrent calls, sized by cfg the first time the backend is used after a
+// configuration change. The returned function gives the slot back.
+func acquireBackend(ctx context.Context, cfg *Config, name string) (func(), error) {
+	settings := cfg.backend(name)
+	backendSlotsMu.Lock()
+	slots, limited := backendSlots[name]
+	if n := settings.Concurrency; !limited && n > 0 {
+		slots, limited = make(chan struct{}, n), true
+		backendSlots[name] = slots
+	}
+	limiter, ok := backendLimits[name]
+	if rpm := settings.RPM; !ok && rpm > 0 {
+		limiter = rate.NewLimiter(rate.Limit(rpm/time.Minute.Seconds()), 1)
+		backendLimits[name] = limiter
+	}
+	backendSlotsMu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if !limited {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// notePool runs the notes of the scans, the watchers and the retry queue,
+// at most scan_workers at once between all of them, so a sync that touches
+// hundreds of notes does not start hundreds of extractions. applyConfig
+// resizes it.
+var notePool = newWorkerPool(builtinConfig.ScanWorkers)
+
+// workerPool runs jobs on up to size goroutines, in the order they were
+// submitted. Workers are started as jobs arrive and stop when there are none
+// left, so an idle pool costs nothing.
+type workerPool struct {
+	mu      sync.Mutex
+	size    int
+	running int
+	queue   []func()
+}
+
+func newWorkerPool(n int) *workerPool {
+	p := &workerPool{}
+	p.resize(n)
+	return p
+}
+
+// Go queues job to run on the next free worker. It does not wait: callers
+// that need to know when their jobs are done use a sync.WaitGroup.
+func (p *workerPool) Go(job func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = append(p.queue, job)
+	p.start()
+}
+
+// resize changes how many jobs run at once. Running jobs are not
+// interrupted; with fewer workers, the extra ones stop after their job.
+func (p *workerPool) resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.size = max(n, 1)
+	p.start()
+}
+
+// start adds workers while there are queued jobs and room for them. Callers
+// must hold p.mu.
+func (p *workerPool) start() {
+	for p.running < p.size && p.running < len(p.queue) {
+		p.running++
+		go p.work()
+	}
+}
+
+func (p *workerPool) work() {
+	for {
+		p.mu.Lock()
+		if len(p.queue) == 0 || p.running > p.size {
+			p.running--
+			p.mu.Unlock()
+			return
+		}
+		job := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		p.mu.Unlock()
+		job()
+	}
+}
+
+// noteLocks makes sure a note is never processed by two goroutines at once,
+// whichever started them: a scan worker, the watcher or the retry queue. A
+// second caller waits and then finds the note already processed, unless it
+// changed in between.
+var noteLocks = keyedMutex{locks: map[string]*refMutex{}}
+
+type refMutex struct {
+	sync.Mutex
+	refs int
+}
+
+// keyedMutex is a mutex per key. Entries are dropped once nobody holds or
+// waits for them.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*refMutex
+}
+
+func (k *keyedMutex) Lock(key string) {
+	k.mu.Lock()
+	m, ok := k.locks[key]
+	if !ok {
+		m = &refMutex{}
+		k.locks[key] = m
+	}
+	m.refs++
+	k.mu.Unlock()
+	m.Lock()
+}
+
+func (k *keyedMutex) Unlock(key string) {
+	k.mu.Lock()
+	m := k.locks[key]
+	m.refs--
+	if m.refs == 0 {
+		delete(k.locks, key)
+	}
+	k.mu.Unlock()
+	m.Unlock()
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolBoundsConcurrency(t *testing.T) {
+	var running, peak, done atomic.Int32
+	var wg sync.WaitGroup
+	pool := newWorkerPool(3)
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		pool.Go(func() {
+			defer wg.Done()
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+		})
+	}
+	wg.Wait()
+
+	if done.Load() != 12 {
+		t.Errorf("Expected 12 jobs, %d ran", done.Load())
+	}
+	if peak.Load() > 3 {
+		t.Errorf("Expected at most 3 concurrent jobs, got %d", peak.Load())
+	}
+}
+
+func TestKeyedMutexSerializesSameKey(t *testing.T) {
+	var k = keyedMutex{locks: map[string]*refMutex{}}
+	var inside atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			k.Lock("a.md")
+			if inside.Add(1) > 1 {
+				t.Error("Two holders of the same key")
+			}
+			time.Sleep(time.Millisecond)
+			inside.Add(-1)
+			k.Unlock("a.md")
+		}()
+	}
+
+	// Otra clave no espera a la primera.
+	k.Lock("b.md")
+	k.Unlock("b.md")
+	wg.Wait()
+
+	if len(k.locks) != 0 {
+		t.Errorf("Expected unused locks to be dropped, got %d", len(k.locks))
+	}
+}
+
+func TestAcquireBackendLimits(t *testing.T) {
+	cfg := &Config{}
+	cfg.OpenAI.Concurrency = 1
+	cfg.Gemini.RPM = 600 // uno cada 100ms
+	clearSlots := func() {
+		backendSlotsMu.Lock()
+		delete(backendSlots, "openai")
+		delete(backendLimits, "gemini")
+		backendSlotsMu.Unlock()
+	}
+	clearSlots()
+	defer clearSlots()
+
+	release, err := acquireBackend(context.Background(), cfg, "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := acquireBackend(ctx, cfg, "openai"); err == nil {
+		t.Error("Expected a second call to wait for the only slot")
+	}
+	release()
+	if release, err = acquireBackend(context.Background(), cfg, "openai"); err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := acquireBackend(context.Background(), cfg, "gemini")
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected the rate limit to space out calls, 3 took %v", elapsed)
+	}
+}
+
+func TestScanProcessesNotesConcurrently(t *testing.T) {
+	setupTestDB(t)
+	var running, peak, calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		calls.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		json.NewEncoder(w).Encode(ollamaAnswer())
+	}))
+	defer ts.Close()
+	useConfig(t, func(cfg *Config) {
+		cfg.Ollama.URL = ts.URL
+		cfg.Ollama.Concurrency = 4
+		cfg.ScanWorkers = 4
+	})
+
+	dir := filepath.Join(t.TempDir(), "Redes")
+	os.MkdirAll(dir, 0755)
+	date := time.Now().Format("2006-01-02")
+	for i := 0; i < 8; i++ {
+		note := filepath.Join(dir, fmt.Sprintf("%s Clase %d.md", date, i))
+		os.WriteFile(note, []byte(fmt.Sprintf("Apuntes de la clase %d\n", i)), 0644)
+	}
+
+	scanAndProcessDirectory(context.Background(), filepath.Dir(dir))
+
+	if calls.Load() != 8 {
+		t.Errorf("Expected 8 extractions, got %d", calls.Load())
+	}
+	if peak.Load() < 2 || peak.Load() > 4 {
+		t.Errorf("Expected between 2 and 4 concurrent extractions, got %d", peak.Load())
+	}
+}
+
+func TestWorkerPoolResize(t *testing.T) {
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	pool := newWorkerPool(1)
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		pool.Go(func() {
+			defer wg.Done()
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+		})
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := running.Load(); n != 1 {
+		t.Fatalf("Expected 1 running job, got %d", n)
+	}
+	pool.resize(3)
+	time.Sleep(20 * time.Millisecond)
+	if n := running.Load(); n != 3 {
+		t.Errorf("Expected 3 running jobs after growing the pool, got %d", n)
+	}
+	close(release)
+	wg.Wait()
+	if peak.Load() > 3 {
+		t.Errorf("Expected at most 3 concurrent jobs, got %d", peak.Load())
+	}
+}
+
+func TestWatcherEventsShareTheNotePool(t *testing.T) {
+	setupTestDB(t)
+	restoreConfig(t)
+
+	var running, peak, calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		calls.Add(1)
+		json.NewEncoder(w).Encode(ollamaAnswer())
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	cfg := builtinConfig
+	cfg.Extractor = "ollama"
+	cfg.Ollama.URL = ts.URL
+	cfg.Ollama.Concurrency = 8
+	cfg.ScanWorkers = 2
+	cfg.WatchDebounce = 10 * time.Millisecond
+	cfg.Roots = []RootConfig{{Path: dir}}
+	applyConfig(cfg)
+
+	notes := &notesService{ctx: context.Background()}
+	notes.restartWatchers()
+	defer notes.close()
+
+	os.MkdirAll(filepath.Join(dir, "Redes"), 0755)
+	time.Sleep(50 * time.Millisecond)
+	date := time.Now().Format("2006-01-02")
+	for i := 0; i < 8; i++ {
+		note := filepath.Join(dir, "Redes", fmt.Sprintf("%s Clase %d.md", date, i))
+		os.WriteFile(note, []byte(fmt.Sprintf("Apuntes de la clase %d\n", i)), 0644)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() < 8 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out with %d of 8 extractions", calls.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("Expected at most scan_workers=2 concurrent extractions, got %d", peak.Load())
+	}
+}